@@ -0,0 +1,83 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"unicode"
+
+	"github.com/gomarkdown/markdown/ast"
+)
+
+const defaultPermalinkSymbol = "#"
+
+// defaultPermalinkClass is the class of heading permalinks when not configured
+const defaultPermalinkClass = "anchor"
+
+// headingSlug turns heading text into an id the same way GitHub does. Letters,
+// numbers, dashes and underscores are kept, spaces become dashes and
+// everything else is dropped.
+func headingSlug(text string) string {
+	var slug strings.Builder
+	for _, r := range strings.ToLower(strings.TrimSpace(text)) {
+		switch {
+		case unicode.IsLetter(r), unicode.IsNumber(r), unicode.IsMark(r), r == '-', r == '_':
+			slug.WriteRune(r)
+		case r == ' ':
+			slug.WriteRune('-')
+		}
+	}
+	return slug.String()
+}
+
+// headingIDs gives every heading in doc an id, unique within the page, and
+// returns the headings in order. Ids set with {#custom-id} are kept and the
+// rest come from the heading text, with -1, -2 and so on added to repeats.
+func headingIDs(doc ast.Node) []*ast.Heading {
+	headings := []*ast.Heading{}
+	taken := map[string]bool{}
+	ast.WalkFunc(doc, func(node ast.Node, entering bool) ast.WalkStatus {
+		if heading, ok := node.(*ast.Heading); ok && entering {
+			headings = append(headings, heading)
+			if heading.HeadingID != "" {
+				taken[heading.HeadingID] = true
+			}
+		}
+		return ast.GoToNext
+	})
+	for _, heading := range headings {
+		if heading.HeadingID != "" {
+			continue
+		}
+		id := headingSlug(headingText(heading))
+		if id == "" {
+			id = "heading"
+		}
+		unique := id
+		for n := 1; taken[unique]; n++ {
+			unique = fmt.Sprintf("%s-%d", id, n)
+		}
+		heading.HeadingID = unique
+		taken[unique] = true
+	}
+	return headings
+}
+
+// permalinkClass returns the class of heading permalinks
+func (config AnchorConfig) permalinkClass() string {
+	if config.Class != "" {
+		return config.Class
+	}
+	return defaultPermalinkClass
+}
+
+// renderPermalink closes a heading with a link to itself
+func renderPermalink(w io.Writer, node *ast.Heading, config AnchorConfig) (ast.WalkStatus, bool) {
+	symbol := config.Symbol
+	if symbol == "" {
+		symbol = defaultPermalinkSymbol
+	}
+	io.WriteString(w, ` <a `+classAttr(config.permalinkClass())+` href="#`+node.HeadingID+`" aria-hidden="true">`+symbol+"</a>")
+	fmt.Fprintf(w, "</h%d>\n", node.Level)
+	return ast.GoToNext, true
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/gomarkdown/markdown"
+)
+
+func TestHeadingSlug(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"Getting Started", "getting-started"},
+		{"Two & More", "two--more"},
+		{"snake_case and dashes-too", "snake_case-and-dashes-too"},
+		{"What's new in v1.2?", "whats-new-in-v12"},
+		{"Überblick", "überblick"},
+		{"Emoji 🎉 party", "emoji--party"},
+	}
+	for _, test := range tests {
+		if got := headingSlug(test.input); got != test.expected {
+			t.Errorf("headingSlug(%q) = %q, expected %q", test.input, got, test.expected)
+		}
+	}
+}
+
+func TestHeadingIDs(t *testing.T) {
+	doc := markdown.Parse([]byte("# Intro\n\n## Set `up`\n\n## Intro\n\n## Intro\n\n## Custom {#intro-2}\n"), nil)
+	headings := headingIDs(doc)
+	expected := []string{"intro", "set-up", "intro-1", "intro-3", "intro-2"}
+	if len(headings) != len(expected) {
+		t.Fatalf("expected %v headings, got %v", len(expected), len(headings))
+	}
+	for i, heading := range headings {
+		if heading.HeadingID != expected[i] {
+			t.Errorf("expected heading %v to have id %q, got %q", i, expected[i], heading.HeadingID)
+		}
+	}
+}
+
+func TestPermalink(t *testing.T) {
+	tests := []struct {
+		name     string
+		config   AnchorConfig
+		expected string
+	}{
+		{"disabled", AnchorConfig{}, `<h2 id="usage">Usage</h2>`},
+		{"default symbol", AnchorConfig{Permalink: true},
+			`<h2 id="usage">Usage <a class="anchor" href="#usage" aria-hidden="true">#</a></h2>`},
+		{"custom symbol and class", AnchorConfig{Permalink: true, Symbol: "¶", Class: "permalink"},
+			`<h2 id="usage">Usage <a class="permalink" href="#usage" aria-hidden="true">¶</a></h2>`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := App{Config: SquatchConfig{Anchors: tt.config}}
+			output := string(app.markdownToHTML([]byte("## Usage\n\nText")))
+			if !strings.Contains(output, tt.expected) {
+				t.Errorf("Expected output to contain %s, got: %s", tt.expected, output)
+			}
+		})
+	}
+}
@@ -0,0 +1,229 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+var (
+	// templateErrorPosition matches the template name, line and column at the
+	// start of template errors, like template: layout_post.html:3:5: ...
+	templateErrorPosition = regexp.MustCompile(`^(?:html/)?template: ?([^:"]+):(\d+)(?::(\d+))?: (.*)$`)
+	// errorLine matches the line number in yaml and toml errors
+	errorLine = regexp.MustCompile(`line (\d+)`)
+)
+
+// BuildError is a problem with one file of the source directory
+type BuildError struct {
+	Path string
+	// Line and Column are 0 when the position in the file is unknown
+	Line   int
+	Column int
+	Err    error
+	// Warning is set for problems that only fail strict builds
+	Warning bool
+}
+
+func (e BuildError) Error() string {
+	position := e.Path
+	if e.Line > 0 {
+		position += ":" + strconv.Itoa(e.Line)
+		if e.Column > 0 {
+			position += ":" + strconv.Itoa(e.Column)
+		}
+	}
+	if position == "" {
+		return e.Err.Error()
+	}
+	return position + ": " + e.Err.Error()
+}
+
+func (e BuildError) Unwrap() error {
+	return e.Err
+}
+
+// BuildReport collects the errors and warnings of a build so they can be
+// reported together
+type BuildReport struct {
+	mu       sync.Mutex
+	seen     map[string]bool
+	Problems []BuildError
+	// Skipped lists the markdown files that were not built as pages
+	Skipped []BuildError
+	// Strict fails the build on warnings too
+	Strict bool
+}
+
+// add records a problem. Errors that are already build errors keep their
+// position, anything else is recorded against fp.
+func (r *BuildReport) add(fp string, err error, warning bool) {
+	var buildErr BuildError
+	if !errors.As(err, &buildErr) {
+		buildErr = BuildError{Path: fp, Err: err}
+	}
+	buildErr.Warning = buildErr.Warning || warning
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	// A broken layout is reported by every page using it, so keep one of
+	// each problem, which is its position and message
+	if r.seen == nil {
+		r.seen = map[string]bool{}
+	}
+	if r.seen[buildErr.Error()] {
+		return
+	}
+	r.seen[buildErr.Error()] = true
+	r.Problems = append(r.Problems, buildErr)
+}
+
+// skip records a markdown file that was not built and why
+func (r *BuildReport) skip(fp string, reason error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.Skipped = append(r.Skipped, BuildError{Path: fp, Err: reason})
+}
+
+// counts returns the number of errors and warnings
+func (r *BuildReport) counts() (int, int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	errs, warnings := 0, 0
+	for _, problem := range r.Problems {
+		if problem.Warning {
+			warnings++
+		} else {
+			errs++
+		}
+	}
+	return errs, warnings
+}
+
+// Err returns the report as an error if the build failed
+func (r *BuildReport) Err() error {
+	errs, warnings := r.counts()
+	if errs > 0 || (r.Strict && warnings > 0) {
+		return r
+	}
+	return nil
+}
+
+// Error lists every problem followed by a summary
+func (r *BuildReport) Error() string {
+	var b strings.Builder
+	r.print(&b)
+	errs, warnings := r.counts()
+	fmt.Fprintf(&b, "build failed with %d error(s) and %d warning(s)", errs, warnings)
+	if r.Strict && warnings > 0 {
+		b.WriteString(" (warnings are errors in strict mode)")
+	}
+	return b.String()
+}
+
+// print writes a line for every skipped file and problem, sorted by file
+// since files are built concurrently
+func (r *BuildReport) print(w io.Writer) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, skipped := range sortedByPath(r.Skipped) {
+		fmt.Fprintf(w, "skipped: %v\n", skipped)
+	}
+	for _, problem := range sortedByPath(r.Problems) {
+		level := "error"
+		if problem.Warning {
+			level = "warning"
+		}
+		fmt.Fprintf(w, "%v: %v\n", level, problem)
+	}
+}
+
+// sortedByPath returns a copy of problems sorted by file and position
+func sortedByPath(problems []BuildError) []BuildError {
+	sorted := append([]BuildError{}, problems...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		a, b := sorted[i], sorted[j]
+		if a.Path != b.Path {
+			return a.Path < b.Path
+		}
+		if a.Line != b.Line {
+			return a.Line < b.Line
+		}
+		return a.Column < b.Column
+	})
+	return sorted
+}
+
+// recordError adds err for the file at fp to the build report so the build
+// can carry on with the other files. Without a report the error is returned.
+func (app App) recordError(fp string, err error) error {
+	if err == nil || app.Report == nil {
+		return err
+	}
+	app.Report.add(fp, err, false)
+	return nil
+}
+
+// warn adds a warning for the file at fp to the build report, or prints it
+// without a report
+func (app App) warn(fp string, err error) {
+	if app.Report == nil {
+		fmt.Println("warning:", BuildError{Path: fp, Err: err})
+		return
+	}
+	app.Report.add(fp, err, true)
+}
+
+// skipPage records the markdown file at fp as skipped, unless it was
+// excluded on purpose
+func (app App) skipPage(fp string, err InvalidPageError) {
+	if err.Excluded || app.Report == nil {
+		return
+	}
+	app.Report.skip(fp, err)
+}
+
+// templateError returns a build error pointing at the template file and
+// position in a template error, or at fp if the error has no position
+func (app App) templateError(fp string, err error) error {
+	match := templateErrorPosition.FindStringSubmatch(err.Error())
+	if match == nil {
+		return BuildError{Path: fp, Err: err}
+	}
+	file, ok := app.templateFile(match[1])
+	if !ok {
+		return BuildError{Path: fp, Err: err}
+	}
+	line, _ := strconv.Atoi(match[2])
+	column, _ := strconv.Atoi(match[3])
+	return BuildError{Path: file, Line: line, Column: column, Err: errors.New(match[4])}
+}
+
+// templateFile returns the source file of a template in the set built by
+// layoutTemplates
+func (app App) templateFile(name string) (string, bool) {
+	if fp, ok := app.PartialPaths[name]; ok {
+		return fp, true
+	}
+	if filepath.Ext(name) != ".html" {
+		return "", false
+	}
+	return filepath.Join(app.SrcDir, filepath.FromSlash(name)), true
+}
+
+// frontMatterError returns a build error for invalid front matter in the page
+// at fp, pointing at the line of the file when the error has one
+func frontMatterError(fp string, err error) error {
+	buildErr := BuildError{Path: fp, Err: err}
+	if match := errorLine.FindStringSubmatch(err.Error()); match != nil {
+		line, _ := strconv.Atoi(match[1])
+		// The front matter starts after the opening delimiter line
+		buildErr.Line = line + 1
+	}
+	return buildErr
+}
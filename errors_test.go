@@ -0,0 +1,225 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// writeSite writes files, keyed by their path, to a new source directory
+// with its dist directory inside the temporary directory. config holds any
+// other .squatch fields, like `"title": "Site"`.
+func writeSite(t testing.TB, config string, files map[string]string) string {
+	dir := t.TempDir()
+	srcDir := filepath.Join(dir, "src")
+	if config != "" {
+		config = ", " + config
+	}
+	files[".squatch"] = `{"dist": "` + filepath.ToSlash(filepath.Join(dir, "dist")) + `"` + config + `}`
+	for name, content := range files {
+		fp := filepath.Join(srcDir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(fp), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(fp, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return srcDir
+}
+
+func TestBuildErrorString(t *testing.T) {
+	err := errors.New("broken")
+	tests := []struct {
+		err      BuildError
+		expected string
+	}{
+		{BuildError{Path: "src/a.md", Err: err}, "src/a.md: broken"},
+		{BuildError{Path: "src/a.md", Line: 3, Err: err}, "src/a.md:3: broken"},
+		{BuildError{Path: "src/a.md", Line: 3, Column: 7, Err: err}, "src/a.md:3:7: broken"},
+		{BuildError{Err: err}, "broken"},
+	}
+	for _, test := range tests {
+		if got := test.err.Error(); got != test.expected {
+			t.Errorf("expected %q, got %q", test.expected, got)
+		}
+	}
+}
+
+func TestTemplateError(t *testing.T) {
+	app := App{SrcDir: "src", PartialPaths: map[string]string{"partial_nav.html": "src/parts/partial_nav.html"}}
+	tests := []struct {
+		err      string
+		expected string
+	}{
+		{`template: docs/layout_post.html:3: function "nope" not defined`, `src/docs/layout_post.html:3: function "nope" not defined`},
+		{`template: layout.html:2:9: executing "layout.html" at <.Nope>: can't evaluate field Nope`, `src/layout.html:2:9: executing "layout.html" at <.Nope>: can't evaluate field Nope`},
+		{`html/template:partial_nav.html:4:2: no such template "x"`, `src/parts/partial_nav.html:4:2: no such template "x"`},
+		{`something else`, `src/page.md: something else`},
+	}
+	for _, test := range tests {
+		if got := app.templateError("src/page.md", errors.New(test.err)).Error(); got != test.expected {
+			t.Errorf("expected %q, got %q", test.expected, got)
+		}
+	}
+}
+
+func TestFrontMatterError(t *testing.T) {
+	app := App{}
+	srcDir := writeSite(t, "", map[string]string{"bad.md": "---\ntitle: ok\nlayout: [broken\n---\n"})
+	_, err := app.getPage(filepath.Join(srcDir, "bad.md"))
+	var buildErr BuildError
+	if !errors.As(err, &buildErr) {
+		t.Fatalf("expected a build error, got %v", err)
+	}
+	if buildErr.Line < 2 {
+		t.Errorf("expected the line of the error in the file, got %v", buildErr.Line)
+	}
+}
+
+func TestBuildReportsProblems(t *testing.T) {
+	srcDir := writeSite(t, "", map[string]string{
+		"layout.html":        "{{.Body}}",
+		"layout_page.html":   "<main>{{.Body}}</main>",
+		"layout_broken.html": "<main>\n{{.Body}\n</main>",
+		"good.md":            "---\ntitle: Good\nlayout: page\n---\n",
+		"bad.md":             "---\ntitle: [bad\n---\n",
+		"broken.md":          "---\ntitle: Broken\nlayout: broken\n---\n",
+		"typo.md":            "---\ntitle: Typo\nlayout: pgae\n---\n",
+	})
+	err := Build(srcDir)
+	report, ok := err.(*BuildReport)
+	if !ok {
+		t.Fatalf("expected a build report, got %v", err)
+	}
+	message := report.Error()
+	for _, expected := range []string{
+		"error: " + filepath.Join(srcDir, "bad.md") + ":",
+		"error: " + filepath.Join(srcDir, "layout_broken.html") + ":2:",
+		"warning: " + filepath.Join(srcDir, "typo.md") + ": could not find layout pgae",
+		"build failed with 2 error(s) and 1 warning(s)",
+	} {
+		if !strings.Contains(message, expected) {
+			t.Errorf("expected the report to contain %q, got %v", expected, message)
+		}
+	}
+	dist := filepath.Join(filepath.Dir(srcDir), "dist")
+	if _, err := os.Stat(filepath.Join(dist, "good.html")); err != nil {
+		t.Errorf("expected the other pages to still be built, got %v", err)
+	}
+}
+
+func TestBuildStrict(t *testing.T) {
+	srcDir := writeSite(t, "", map[string]string{
+		"layout.html": "{{.Body}}",
+		"typo.md":     "---\ntitle: Typo\nlayout: pgae\n---\n",
+	})
+	if err := build(srcDir, false); err != nil {
+		t.Errorf("expected warnings to pass the build, got %v", err)
+	}
+	err := build(srcDir, true)
+	if err == nil || !strings.Contains(err.Error(), "warnings are errors in strict mode") {
+		t.Errorf("expected warnings to fail a strict build, got %v", err)
+	}
+}
+
+func TestBuildReportsSkippedPages(t *testing.T) {
+	srcDir := writeSite(t, "", map[string]string{
+		"layout.html": "{{.Body}}",
+		"README.md":   "# Readme\n\n{{< missing >}}",
+		"draft.md":    "---\ntitle: Draft\nlayout: page\nbuild: false\n---\n",
+		"notitle.md":  "---\nlayout: page\n---\n",
+	})
+	app, err := InitApp(srcDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	skipped := map[string]string{}
+	for _, file := range app.Report.Skipped {
+		skipped[filepath.Base(file.Path)] = file.Err.Error()
+	}
+	expected := map[string]string{
+		"README.md":  "no title found",
+		"notitle.md": "no title found",
+	}
+	if !reflect.DeepEqual(skipped, expected) {
+		t.Errorf("expected skipped files %v, got %v", expected, skipped)
+	}
+	if err := app.Report.Err(); err != nil {
+		t.Errorf("expected skipped files not to fail the build, got %v", err)
+	}
+}
+
+func TestBuildReportsLayoutErrorOnce(t *testing.T) {
+	srcDir := writeSite(t, "", map[string]string{
+		"layout.html":        "{{.Body}}",
+		"layout_broken.html": "<main>\n{{.Body}\n</main>",
+		"one.md":             "---\ntitle: One\nlayout: broken\n---\n",
+		"two.md":             "---\ntitle: Two\nlayout: broken\n---\n",
+		"three.md":           "---\ntitle: Three\nlayout: broken\n---\n",
+	})
+	app, err := InitApp(srcDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	app.renderPages()
+	if len(app.Report.Problems) != 1 {
+		t.Errorf("expected the broken layout to be reported once, got %v", app.Report.Problems)
+	}
+}
+
+func TestRenderGeneratedRecordsErrors(t *testing.T) {
+	srcDir := writeSite(t, `"baseURL": "https://example.com"`, map[string]string{
+		"layout.html":      "{{.Body}}",
+		"layout_page.html": "{{.Body}}",
+		"page.md":          "---\ntitle: Page\nlayout: page\n---\n",
+	})
+	app, err := InitApp(srcDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// A folder in the way of robots.txt can't be written over
+	if err := os.MkdirAll(filepath.Join(app.DistDir, "robots.txt"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := app.renderSite(); err != nil {
+		t.Fatalf("expected the problem to be recorded, got %v", err)
+	}
+	if len(app.Report.Problems) != 1 || app.Report.Problems[0].Path != filepath.Join(app.DistDir, "robots.txt") {
+		t.Errorf("expected a problem with robots.txt, got %v", app.Report.Problems)
+	}
+	if _, err := os.Stat(filepath.Join(app.DistDir, "page.html")); err != nil {
+		t.Errorf("expected the pages to still be built, got %v", err)
+	}
+}
+
+func TestBuildReportsCopyErrors(t *testing.T) {
+	srcDir := writeSite(t, "", map[string]string{
+		"layout.html":      "{{.Body}}",
+		"layout_page.html": "{{.Body}}",
+		"page.md":          "---\ntitle: Page\nlayout: page\n---\n",
+		"static/a.css":     "a {}",
+		"static/b.css":     "b {}",
+	})
+	// A folder in the way of a.css can't be written over
+	dist := filepath.Join(filepath.Dir(srcDir), "dist")
+	if err := os.MkdirAll(filepath.Join(dist, "static", "a.css"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	app := App{SrcDir: srcDir, DistDir: dist, Report: &BuildReport{}}
+	if err := app.parseSrcDirectory(); err != nil {
+		t.Fatalf("expected the copy error to be recorded, got %v", err)
+	}
+	if len(app.Report.Problems) != 1 || app.Report.Problems[0].Path != filepath.Join(srcDir, "static", "a.css") {
+		t.Errorf("expected a problem with a.css, got %v", app.Report.Problems)
+	}
+	if _, err := os.Stat(filepath.Join(dist, "static", "b.css")); err != nil {
+		t.Errorf("expected the other files to be copied, got %v", err)
+	}
+	if len(app.Pages) != 1 {
+		t.Errorf("expected the pages to still be read, got %v", app.Pages)
+	}
+}
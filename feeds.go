@@ -0,0 +1,219 @@
+package main
+
+import (
+	"encoding/xml"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// defaultFeedLimit is the number of items in a feed when not configured
+const defaultFeedLimit = 20
+
+// linkAttr matches href and src attributes so feed bodies can use absolute links
+var linkAttr = regexp.MustCompile(`(href|src)="([^"]*)"`)
+
+// firstParagraph matches the first paragraph of a rendered page
+var firstParagraph = regexp.MustCompile(`(?s)<p[^>]*>.*?</p>`)
+
+type rss struct {
+	XMLName xml.Name   `xml:"rss"`
+	Version string     `xml:"version,attr"`
+	Channel rssChannel `xml:"channel"`
+}
+
+type rssChannel struct {
+	Title         string    `xml:"title"`
+	Link          string    `xml:"link"`
+	Description   string    `xml:"description"`
+	LastBuildDate string    `xml:"lastBuildDate"`
+	Items         []rssItem `xml:"item"`
+}
+
+type rssItem struct {
+	Title       string `xml:"title"`
+	Link        string `xml:"link"`
+	GUID        string `xml:"guid"`
+	PubDate     string `xml:"pubDate"`
+	Description string `xml:"description"`
+}
+
+type atomFeed struct {
+	XMLName xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	Title   string      `xml:"title"`
+	ID      string      `xml:"id"`
+	Links   []atomLink  `xml:"link"`
+	Updated string      `xml:"updated"`
+	Author  atomAuthor  `xml:"author"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+type atomAuthor struct {
+	Name string `xml:"name"`
+}
+
+type atomLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr,omitempty"`
+}
+
+type atomEntry struct {
+	Title   string      `xml:"title"`
+	ID      string      `xml:"id"`
+	Link    atomLink    `xml:"link"`
+	Updated string      `xml:"updated"`
+	Content atomContent `xml:"content"`
+}
+
+type atomContent struct {
+	Type string `xml:"type,attr"`
+	Body string `xml:",chardata"`
+}
+
+// absURL returns the absolute url of a path from the site root
+func (app App) absURL(path string) string {
+	return strings.TrimSuffix(app.Config.BaseURL, "/") + "/" + strings.TrimPrefix(path, "/")
+}
+
+// absoluteLinks rewrites the links in body, which belongs to the page at
+// pageURL, to absolute urls since feed readers have no base url. Links from
+// the site root keep the path of baseURL, like absURL.
+func (app App) absoluteLinks(body string, pageURL string) string {
+	base, err := url.Parse(app.absURL(pageURL))
+	if err != nil {
+		return body
+	}
+	return linkAttr.ReplaceAllStringFunc(body, func(attr string) string {
+		match := linkAttr.FindStringSubmatch(attr)
+		ref, err := url.Parse(match[2])
+		if err != nil || ref.IsAbs() || strings.HasPrefix(match[2], "#") {
+			return attr
+		}
+		if ref.Host == "" && strings.HasPrefix(ref.Path, "/") {
+			return fmt.Sprintf(`%v="%v"`, match[1], app.absURL(match[2]))
+		}
+		return fmt.Sprintf(`%v="%v"`, match[1], base.ResolveReference(ref))
+	})
+}
+
+// feedPages returns the dated pages in the configured section, newest first
+func (app App) feedPages(config FeedConfig) []Page {
+	pages := []Page{}
+	for _, page := range app.Pages {
+		if page.Date.IsZero() {
+			continue
+		}
+		if config.Section != "" && page.Section != config.Section {
+			continue
+		}
+		pages = append(pages, page)
+	}
+	sort.SliceStable(pages, func(i, j int) bool {
+		return pages[i].Date.After(pages[j].Date)
+	})
+	limit := config.Limit
+	if limit <= 0 {
+		limit = defaultFeedLimit
+	}
+	if len(pages) > limit {
+		pages = pages[:limit]
+	}
+	return pages
+}
+
+// feedContent returns the html of a page in a feed, either the full body or a
+// summary from the summary or description params or the first paragraph
+func (app App) feedContent(page Page, fullContent bool) string {
+	content := string(page.Body)
+	if !fullContent {
+		if summary := stringParam(page.Params, "summary"); summary != "" {
+			content = summary
+		} else if description := stringParam(page.Params, "description"); description != "" {
+			content = description
+		} else if paragraph := firstParagraph.FindString(string(page.Body)); paragraph != "" {
+			content = paragraph
+		}
+	}
+	return app.absoluteLinks(content, page.URL)
+}
+
+// renderFeeds writes feed.xml as RSS 2.0 and atom.xml as Atom to the root of
+// the dist directory when feeds are configured
+func (app App) renderFeeds() error {
+	config := app.Config.Feed
+	if config == nil {
+		return nil
+	}
+	title := config.Title
+	if title == "" {
+		title = app.Config.Title
+	}
+	// Atom feeds need an author, which defaults to the feed title
+	author := app.Config.Author
+	if author == "" {
+		author = title
+	}
+	pages := app.feedPages(*config)
+	updated := app.BuildTime
+	if len(pages) > 0 {
+		updated = pages[0].Date
+	}
+
+	channel := rssChannel{
+		Title:         title,
+		Link:          app.absURL("/"),
+		Description:   config.Description,
+		LastBuildDate: updated.Format(time.RFC1123Z),
+	}
+	atom := atomFeed{
+		Title: title,
+		ID:    app.absURL("/"),
+		Links: []atomLink{
+			{Href: app.absURL("/")},
+			{Href: app.absURL("/atom.xml"), Rel: "self"},
+		},
+		Updated: updated.Format(time.RFC3339),
+		Author:  atomAuthor{Name: author},
+	}
+	for _, page := range pages {
+		link := app.absURL(page.URL)
+		content := app.feedContent(page, config.FullContent)
+		channel.Items = append(channel.Items, rssItem{
+			Title:       page.Title,
+			Link:        link,
+			GUID:        link,
+			PubDate:     page.Date.Format(time.RFC1123Z),
+			Description: content,
+		})
+		atom.Entries = append(atom.Entries, atomEntry{
+			Title:   page.Title,
+			ID:      link,
+			Link:    atomLink{Href: link},
+			Updated: page.Date.Format(time.RFC3339),
+			Content: atomContent{Type: "html", Body: content},
+		})
+	}
+
+	err := app.writeXML("feed.xml", rss{Version: "2.0", Channel: channel})
+	if err != nil {
+		return err
+	}
+	return app.writeXML("atom.xml", atom)
+}
+
+// writeXML writes v as an xml document to name in the dist directory.
+// Problems are recorded against the file.
+func (app App) writeXML(name string, v interface{}) error {
+	fp := filepath.Join(app.DistDir, name)
+	data, err := xml.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return app.recordError(fp, err)
+	}
+	data = append([]byte(xml.Header), data...)
+	return app.recordError(fp, os.WriteFile(fp, data, 0644))
+}
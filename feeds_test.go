@@ -0,0 +1,113 @@
+package main
+
+import (
+	"encoding/xml"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestAbsURL(t *testing.T) {
+	app := App{Config: SquatchConfig{BaseURL: "https://example.com/docs/"}}
+	if url := app.absURL("/pages/example.html"); url != "https://example.com/docs/pages/example.html" {
+		t.Errorf("expected https://example.com/docs/pages/example.html, got %v", url)
+	}
+	if url := app.absURL("/"); url != "https://example.com/docs/" {
+		t.Errorf("expected https://example.com/docs/, got %v", url)
+	}
+}
+
+func TestAbsoluteLinks(t *testing.T) {
+	body := `<a href="other.html">a</a><a href="/root.html">b</a><img src="../img.png"><a href="#top">c</a><a href="https://go.dev">d</a><a href="//cdn.example.com/x.js">e</a>`
+	expected := `<a href="https://example.com/docs/blog/other.html">a</a><a href="https://example.com/docs/root.html">b</a><img src="https://example.com/docs/img.png"><a href="#top">c</a><a href="https://go.dev">d</a><a href="https://cdn.example.com/x.js">e</a>`
+	app := App{Config: SquatchConfig{BaseURL: "https://example.com/docs"}}
+	if output := app.absoluteLinks(body, "/blog/post.html"); output != expected {
+		t.Errorf("expected %v, got %v", expected, output)
+	}
+}
+
+func TestFeedContent(t *testing.T) {
+	app := App{Config: SquatchConfig{BaseURL: "https://example.com"}}
+	page := Page{URL: "/post.html", Body: "<p>First</p>\n<p>Second</p>", Params: map[string]interface{}{}}
+	if content := app.feedContent(page, true); content != string(page.Body) {
+		t.Errorf("expected the full body, got %v", content)
+	}
+	if content := app.feedContent(page, false); content != "<p>First</p>" {
+		t.Errorf("expected the first paragraph, got %v", content)
+	}
+	page.Params["summary"] = "Summary"
+	if content := app.feedContent(page, false); content != "Summary" {
+		t.Errorf("expected the summary param, got %v", content)
+	}
+}
+
+func TestRenderFeeds(t *testing.T) {
+	srcTest := "src_test"
+	defer cleanup("dist")
+	Build(srcTest)
+
+	data, err := os.ReadFile(filepath.Join("dist", "feed.xml"))
+	if err != nil {
+		t.Fatalf("expected feed.xml to exist, got %v", err)
+	}
+	var feed rss
+	if err := xml.Unmarshal(data, &feed); err != nil {
+		t.Fatalf("expected feed.xml to be valid xml, got %v", err)
+	}
+	if feed.Channel.Title != "Squatch Test" {
+		t.Errorf("expected the site title, got %v", feed.Channel.Title)
+	}
+	if len(feed.Channel.Items) != 2 {
+		t.Fatalf("expected 2 items, got %d", len(feed.Channel.Items))
+	}
+	item := feed.Channel.Items[0]
+	if item.Title != "Third Post" || item.Link != "https://example.com/docs/blog/third.html" {
+		t.Errorf("expected the newest post first, got %v at %v", item.Title, item.Link)
+	}
+	if !strings.Contains(item.Description, `href="https://example.com/docs/blog/2023-01-01-first.html"`) {
+		t.Errorf("expected absolute links in the summary, got %v", item.Description)
+	}
+
+	data, err = os.ReadFile(filepath.Join("dist", "atom.xml"))
+	if err != nil {
+		t.Fatalf("expected atom.xml to exist, got %v", err)
+	}
+	var atom atomFeed
+	if err := xml.Unmarshal(data, &atom); err != nil {
+		t.Fatalf("expected atom.xml to be valid xml, got %v", err)
+	}
+	if len(atom.Entries) != 2 || atom.Entries[1].Title != "Second Post" {
+		t.Errorf("expected the two newest posts, got %v", atom.Entries)
+	}
+	if atom.Updated != "2023-03-01T00:00:00Z" {
+		t.Errorf("expected the feed to be updated with the newest post, got %v", atom.Updated)
+	}
+	if atom.Author.Name != "Squatch Test" {
+		t.Errorf("expected the site title as the author without an author, got %v", atom.Author.Name)
+	}
+}
+
+func TestRenderFeedsAuthor(t *testing.T) {
+	app := App{DistDir: t.TempDir(), Config: SquatchConfig{Title: "Site", Author: "Jane Doe", Feed: &FeedConfig{}}}
+	if err := app.renderFeeds(); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(filepath.Join(app.DistDir, "atom.xml"))
+	if err != nil {
+		t.Fatalf("expected atom.xml to exist, got %v", err)
+	}
+	if !strings.Contains(string(data), "<author>\n    <name>Jane Doe</name>\n  </author>") {
+		t.Errorf("expected the configured author, got %s", string(data))
+	}
+}
+
+func TestRenderFeedsNotConfigured(t *testing.T) {
+	app := App{DistDir: t.TempDir()}
+	if err := app.renderFeeds(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(app.DistDir, "feed.xml")); err == nil {
+		t.Errorf("expected no feed without feed config")
+	}
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// metadataLine matches link reference metadata like [_metadata_:title]:- "Title"
+var metadataLine = regexp.MustCompile(`^\[_metadata_:([^\]]+)\]:-\s*"(.*)"\s*$`)
+
+// pageKey matches a title or layout key in a yaml or toml block
+var pageKey = regexp.MustCompile(`(?m)^\s*(title|layout)\s*[:=]`)
+
+// Front matter formats, named after the delimiter that opens the block
+const (
+	yamlFrontMatter = "---"
+	tomlFrontMatter = "+++"
+)
+
+// splitFrontMatter separates the front matter block at the start of a
+// markdown file from the content. The delimiter is empty if there is no
+// front matter.
+func splitFrontMatter(md string) (delimiter string, matter string, content string) {
+	lines := strings.Split(md, "\n")
+	if len(lines) == 0 {
+		return "", "", md
+	}
+	delimiter = strings.TrimSpace(lines[0])
+	if delimiter != yamlFrontMatter && delimiter != tomlFrontMatter {
+		return "", "", md
+	}
+	for i := 1; i < len(lines); i++ {
+		if strings.TrimSpace(lines[i]) == delimiter {
+			matter = strings.Join(lines[1:i], "\n")
+			content = strings.Join(lines[i+1:], "\n")
+			return delimiter, matter, content
+		}
+	}
+	// An unclosed block is a horizontal rule rather than front matter
+	return "", "", md
+}
+
+// parseFrontMatter decodes a front matter block into page params
+func parseFrontMatter(delimiter string, matter string) (map[string]interface{}, error) {
+	params := map[string]interface{}{}
+	var err error
+	switch delimiter {
+	case yamlFrontMatter:
+		err = yaml.Unmarshal([]byte(matter), &params)
+	case tomlFrontMatter:
+		_, err = toml.Decode(matter, &params)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid front matter: %w", err)
+	}
+	// An empty yaml document decodes to a nil map
+	if params == nil {
+		params = map[string]interface{}{}
+	}
+	return params, nil
+}
+
+// namesPage reports whether a front matter block sets a title or layout, so
+// that errors in it are errors in a page rather than a markdown file that
+// starts with a horizontal rule
+func namesPage(matter string) bool {
+	return pageKey.MatchString(matter)
+}
+
+// stringParam returns the param key as a string, or an empty string if it is
+// missing
+func stringParam(params map[string]interface{}, key string) string {
+	value, ok := params[key]
+	if !ok || value == nil {
+		return ""
+	}
+	if s, ok := value.(string); ok {
+		return s
+	}
+	return fmt.Sprint(value)
+}
+
+// parseMetadata adds every [_metadata_:<key>]:- "<value>" line in content to
+// params, overriding any front matter with the same key
+func parseMetadata(content string, params map[string]interface{}) {
+	for _, line := range strings.Split(content, "\n") {
+		match := metadataLine.FindStringSubmatch(line)
+		if match == nil {
+			continue
+		}
+		params[strings.TrimSpace(match[1])] = metadataValue(match[2])
+	}
+}
+
+// metadataValue converts a metadata value to a bool for true and false, a list
+// for values like [go, markdown] and leaves anything else as a string
+func metadataValue(value string) interface{} {
+	switch value {
+	case "true":
+		return true
+	case "false":
+		return false
+	}
+	if strings.HasPrefix(value, "[") && strings.HasSuffix(value, "]") {
+		var list []interface{}
+		if err := yaml.Unmarshal([]byte(value), &list); err == nil {
+			return list
+		}
+	}
+	return value
+}
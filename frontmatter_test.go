@@ -0,0 +1,161 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestSplitFrontMatter(t *testing.T) {
+	tests := []struct {
+		name      string
+		md        string
+		delimiter string
+		matter    string
+		content   string
+	}{
+		{"yaml", "---\ntitle: Yaml\n---\n# Body", yamlFrontMatter, "title: Yaml", "# Body"},
+		{"toml", "+++\ntitle = \"Toml\"\n+++\n# Body", tomlFrontMatter, "title = \"Toml\"", "# Body"},
+		{"crlf", "---\r\ntitle: Yaml\r\n---\r\n# Body", yamlFrontMatter, "title: Yaml\r", "# Body"},
+		{"none", "# Body\n---\n", "", "", "# Body\n---\n"},
+		{"unclosed", "---\n# Body", "", "", "---\n# Body"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			delimiter, matter, content := splitFrontMatter(tt.md)
+			if delimiter != tt.delimiter {
+				t.Errorf("expected delimiter %q, got %q", tt.delimiter, delimiter)
+			}
+			if matter != tt.matter {
+				t.Errorf("expected matter %q, got %q", tt.matter, matter)
+			}
+			if content != tt.content {
+				t.Errorf("expected content %q, got %q", tt.content, content)
+			}
+		})
+	}
+}
+
+func TestParseFrontMatterYAML(t *testing.T) {
+	params, err := parseFrontMatter(yamlFrontMatter, "title: Yaml\ndate: 2023-01-15\ndraft: true\ntags: [go, yaml]\nauthor:\n  name: Jane")
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if params["title"] != "Yaml" {
+		t.Errorf("expected title to be Yaml, got %v", params["title"])
+	}
+	if params["draft"] != true {
+		t.Errorf("expected draft to be true, got %v", params["draft"])
+	}
+	if date, ok := params["date"].(time.Time); !ok || date.Year() != 2023 {
+		t.Errorf("expected date to be a time in 2023, got %v", params["date"])
+	}
+	if tags, ok := params["tags"].([]interface{}); !ok || len(tags) != 2 {
+		t.Errorf("expected two tags, got %v", params["tags"])
+	}
+	if author, ok := params["author"].(map[string]interface{}); !ok || author["name"] != "Jane" {
+		t.Errorf("expected author name to be Jane, got %v", params["author"])
+	}
+}
+
+func TestParseFrontMatterTOML(t *testing.T) {
+	params, err := parseFrontMatter(tomlFrontMatter, "title = \"Toml\"\nweight = 3\ntags = [\"go\", \"toml\"]")
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if params["title"] != "Toml" {
+		t.Errorf("expected title to be Toml, got %v", params["title"])
+	}
+	if params["weight"] != int64(3) {
+		t.Errorf("expected weight to be 3, got %v", params["weight"])
+	}
+}
+
+func TestParseFrontMatterEmpty(t *testing.T) {
+	params, err := parseFrontMatter(yamlFrontMatter, "")
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if params == nil {
+		t.Errorf("expected empty params, got nil")
+	}
+}
+
+func TestParseFrontMatterInvalid(t *testing.T) {
+	if _, err := parseFrontMatter(yamlFrontMatter, "title: [unclosed"); err == nil {
+		t.Errorf("expected an error for invalid yaml")
+	}
+	if _, err := parseFrontMatter(tomlFrontMatter, "title = "); err == nil {
+		t.Errorf("expected an error for invalid toml")
+	}
+}
+
+func TestNamesPage(t *testing.T) {
+	tests := map[string]bool{
+		"title: [unclosed":        true,
+		"author: Jane\nlayout: x": true,
+		`title = "TOML"`:          true,
+		"Some notes: with colons": false,
+		"- a list\n- of things":   false,
+	}
+	for matter, expected := range tests {
+		if got := namesPage(matter); got != expected {
+			t.Errorf("namesPage(%q) = %v, expected %v", matter, got, expected)
+		}
+	}
+}
+
+func TestGetPageHorizontalRules(t *testing.T) {
+	srcDir := writeSite(t, "", map[string]string{
+		"NOTES.md": "---\nSome notes: [not yaml\n---\nMore notes",
+		"page.md":  "---\nIntro: [not yaml\n---\n[_metadata_:title]:- \"Page\"\n[_metadata_:layout]:- \"page\"\n",
+	})
+	app := App{SrcDir: srcDir}
+	if _, err := app.getPage(filepath.Join(srcDir, "NOTES.md")); err == nil {
+		t.Errorf("expected NOTES.md not to be a page")
+	} else if _, ok := err.(InvalidPageError); !ok {
+		t.Errorf("expected NOTES.md to be skipped as a non-page, got %v", err)
+	}
+	page, err := app.getPage(filepath.Join(srcDir, "page.md"))
+	if err != nil {
+		t.Fatalf("expected page.md to be a page, got %v", err)
+	}
+	if page.Title != "Page" || page.Layout != "page" {
+		t.Errorf("expected the metadata of page.md, got %q and %q", page.Title, page.Layout)
+	}
+}
+
+func TestStringParam(t *testing.T) {
+	params := map[string]interface{}{"title": "Title", "weight": 3, "empty": nil}
+	if stringParam(params, "title") != "Title" {
+		t.Errorf("expected Title, got %v", stringParam(params, "title"))
+	}
+	if stringParam(params, "weight") != "3" {
+		t.Errorf("expected 3, got %v", stringParam(params, "weight"))
+	}
+	if stringParam(params, "empty") != "" || stringParam(params, "missing") != "" {
+		t.Errorf("expected missing params to be empty")
+	}
+}
+
+func TestParseMetadata(t *testing.T) {
+	params := map[string]interface{}{"title": "Front Matter", "author": "Jane"}
+	content := "[_metadata_:title]:- \"Metadata\"\n[_metadata_:draft]:- \"true\"\r\n[_metadata_:tags]:- \"[go, markdown]\"\n[_metadata_:note]:- \"[not a list\"\n\n# Heading\n"
+	parseMetadata(content, params)
+	if params["title"] != "Metadata" {
+		t.Errorf("expected metadata to override the title, got %v", params["title"])
+	}
+	if params["author"] != "Jane" {
+		t.Errorf("expected front matter author to be kept, got %v", params["author"])
+	}
+	if params["draft"] != true {
+		t.Errorf("expected draft to be true, got %v", params["draft"])
+	}
+	tags, ok := params["tags"].([]interface{})
+	if !ok || len(tags) != 2 || tags[0] != "go" || tags[1] != "markdown" {
+		t.Errorf("expected tags to be [go markdown], got %v", params["tags"])
+	}
+	if params["note"] != "[not a list" {
+		t.Errorf("expected note to stay a string, got %v", params["note"])
+	}
+}
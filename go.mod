@@ -3,8 +3,14 @@ module github.com/themcaffee/GoSquatch
 go 1.19
 
 require (
+	github.com/BurntSushi/toml v1.2.1
+	github.com/alecthomas/chroma/v2 v2.14.0
 	github.com/fsnotify/fsnotify v1.7.0
 	github.com/gomarkdown/markdown v0.0.0-20220905174103-7b278df48cfb
+	gopkg.in/yaml.v3 v3.0.1
 )
 
-require golang.org/x/sys v0.4.0 // indirect
+require (
+	github.com/dlclark/regexp2 v1.11.0 // indirect
+	golang.org/x/sys v0.4.0 // indirect
+)
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/alecthomas/chroma/v2"
+	chromahtml "github.com/alecthomas/chroma/v2/formatters/html"
+	"github.com/alecthomas/chroma/v2/lexers"
+	"github.com/alecthomas/chroma/v2/styles"
+	"github.com/gomarkdown/markdown/ast"
+)
+
+const defaultHighlightStyle = "github"
+
+// fenceRanges matches a code fence with lines to highlight, like ```go {3-5,8}.
+// The markdown parser only allows one word after a fence, so these are
+// rewritten to ```{go 3-5,8} before parsing.
+var fenceRanges = regexp.MustCompile("^( {0,3})(```+|~~~+)[ \t]*([^\\s{}]+)[ \t]+\\{([\\d ,-]+)\\}[ \t]*$")
+
+// style returns the chroma style of the config
+func (config HighlightConfig) style() (*chroma.Style, error) {
+	name := config.Style
+	if name == "" {
+		name = defaultHighlightStyle
+	}
+	style, ok := styles.Registry[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown highlight style %q", name)
+	}
+	return style, nil
+}
+
+// highlightFences moves the highlighted lines of code fences into the info
+// string so the markdown parser keeps them. Fence lines that are content of
+// another code block are left alone.
+func highlightFences(md []byte) []byte {
+	var out bytes.Buffer
+	fence := ""
+	for _, line := range strings.SplitAfter(string(md), "\n") {
+		text := strings.TrimRight(line, "\r\n")
+		match := codeFence.FindStringSubmatch(text)
+		switch {
+		case fence == "" && match != nil:
+			fence = match[1]
+			line = fenceRanges.ReplaceAllString(text, "$1$2{$3 $4}") + line[len(text):]
+		case fence != "" && match != nil && match[1][0] == fence[0] && len(match[1]) >= len(fence) &&
+			strings.TrimSpace(text[len(match[0]):]) == "":
+			fence = ""
+		}
+		out.WriteString(line)
+	}
+	return out.Bytes()
+}
+
+// codeInfo splits a code block's info string into its language and the
+// ranges of lines to highlight
+func codeInfo(info string) (string, [][2]int) {
+	fields := strings.Fields(info)
+	if len(fields) == 0 {
+		return "", nil
+	}
+	var ranges [][2]int
+	for _, part := range strings.Split(strings.Join(fields[1:], ""), ",") {
+		bounds := strings.SplitN(part, "-", 2)
+		start, err := strconv.Atoi(bounds[0])
+		if err != nil {
+			continue
+		}
+		end := start
+		if len(bounds) == 2 {
+			if end, err = strconv.Atoi(bounds[1]); err != nil || end < start {
+				continue
+			}
+		}
+		ranges = append(ranges, [2]int{start, end})
+	}
+	return fields[0], ranges
+}
+
+// renderHighlightedCode writes a code block colored by its language. Blocks
+// that can't be highlighted are written as plain code blocks.
+func renderHighlightedCode(w io.Writer, block *ast.CodeBlock, config HighlightConfig, class string) (ast.WalkStatus, bool) {
+	lang, ranges := codeInfo(string(block.Info))
+	lexer := lexers.Get(lang)
+	if lexer == nil {
+		lexer = lexers.Fallback
+	}
+	// The config is checked when it is loaded
+	style, _ := config.style()
+	formatter := chromahtml.New(
+		chromahtml.WithClasses(config.Classes),
+		chromahtml.WithLineNumbers(config.LineNumbers),
+		chromahtml.HighlightLines(ranges),
+	)
+	iterator, err := chroma.Coalesce(lexer).Tokenise(nil, string(block.Literal))
+	if err != nil {
+		return renderCodeBlock(w, block, class)
+	}
+	var code bytes.Buffer
+	if err := formatter.Format(&code, style, iterator); err != nil {
+		return renderCodeBlock(w, block, class)
+	}
+	io.WriteString(w, "\n")
+	if class != "" {
+		io.WriteString(w, "<div "+classAttr(class)+">")
+	}
+	w.Write(code.Bytes())
+	if class != "" {
+		io.WriteString(w, "</div>")
+	}
+	io.WriteString(w, "\n")
+	return ast.GoToNext, true
+}
+
+// writeHighlightCSS writes the css for a highlight style, for sites using
+// classes instead of inline styles
+func writeHighlightCSS(w io.Writer, name string) error {
+	style, err := HighlightConfig{Style: name}.style()
+	if err != nil {
+		return err
+	}
+	return chromahtml.New(chromahtml.WithClasses(true)).WriteCSS(w, style)
+}
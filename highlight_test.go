@@ -0,0 +1,114 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCodeInfo(t *testing.T) {
+	tests := []struct {
+		info   string
+		lang   string
+		ranges [][2]int
+	}{
+		{"", "", nil},
+		{"go", "go", nil},
+		{"go 3-5", "go", [][2]int{{3, 5}}},
+		{"python 1, 4-6,9", "python", [][2]int{{1, 1}, {4, 6}, {9, 9}}},
+		{"go 5-3", "go", nil},
+	}
+	for _, test := range tests {
+		lang, ranges := codeInfo(test.info)
+		if lang != test.lang || !reflect.DeepEqual(ranges, test.ranges) {
+			t.Errorf("codeInfo(%q) = %q, %v, expected %q, %v", test.info, lang, ranges, test.lang, test.ranges)
+		}
+	}
+}
+
+func TestHighlightFences(t *testing.T) {
+	tests := []struct {
+		md       string
+		expected string
+	}{
+		{"```go {3-5}\ncode\n```", "```{go 3-5}\ncode\n```"},
+		{"~~~~ js {1,2} \ncode\n~~~~", "~~~~{js 1,2}\ncode\n~~~~"},
+		{"```go\ncode\n```", "```go\ncode\n```"},
+		{"text {3-5}", "text {3-5}"},
+		{"````md\n```go {3-5,8}\n```\n````", "````md\n```go {3-5,8}\n```\n````"},
+		{"````md\n```\n````\n```go {2}\ncode\n```", "````md\n```\n````\n```{go 2}\ncode\n```"},
+		{"```go {2}\r\ncode\r\n```", "```{go 2}\r\ncode\r\n```"},
+	}
+	for _, test := range tests {
+		if got := string(highlightFences([]byte(test.md))); got != test.expected {
+			t.Errorf("highlightFences(%q) = %q, expected %q", test.md, got, test.expected)
+		}
+	}
+}
+
+func TestRenderFencesWithoutHighlight(t *testing.T) {
+	app := App{}
+	body := string(app.markdownToHTML([]byte("````md\n```go {3-5,8}\n```\n````\n")))
+	if !strings.Contains(body, "```go {3-5,8}") {
+		t.Errorf("expected the fence in the code block to be kept, got %v", body)
+	}
+}
+
+func TestHighlightCodeBlocks(t *testing.T) {
+	md := "```go {2}\npackage main\nfunc main() {}\n```\n"
+	tests := []struct {
+		name     string
+		config   HighlightConfig
+		theme    ThemeConfig
+		expected []string
+	}{
+		{"inline styles", HighlightConfig{}, ThemeConfig{},
+			[]string{`<pre style="background-color:#fff;`, `<span style="color:#000;font-weight:bold">package</span>`}},
+		{"classes", HighlightConfig{Classes: true}, ThemeConfig{},
+			[]string{`<pre class="chroma">`, `<span class="kn">package</span>`, `<span class="line hl">`}},
+		{"line numbers", HighlightConfig{Classes: true, LineNumbers: true}, ThemeConfig{},
+			[]string{`<span class="ln">2</span>`}},
+		{"theme class", HighlightConfig{Classes: true}, ThemeConfig{CodeBlock: CodeBlock{Class: "box"}},
+			[]string{`<div class="box"><pre class="chroma">`}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := App{ThemeConfig: tt.theme, Config: SquatchConfig{Highlight: &tt.config}}
+			output := string(app.markdownToHTML([]byte(md)))
+			for _, expected := range tt.expected {
+				if !strings.Contains(output, expected) {
+					t.Errorf("Expected output to contain %s, got: %s", expected, output)
+				}
+			}
+		})
+	}
+}
+
+func TestHighlightUnknownLanguage(t *testing.T) {
+	app := App{Config: SquatchConfig{Highlight: &HighlightConfig{Classes: true}}}
+	output := string(app.markdownToHTML([]byte("```nope\n<b>plain</b>\n```\n")))
+	if !strings.Contains(output, "&lt;b&gt;plain&lt;/b&gt;") {
+		t.Errorf("expected unknown languages to be written as plain text, got %s", output)
+	}
+}
+
+func TestWriteHighlightCSS(t *testing.T) {
+	var css bytes.Buffer
+	if err := writeHighlightCSS(&css, "monokai"); err != nil {
+		t.Fatalf("expected writeHighlightCSS to return no error, got %v", err)
+	}
+	if !strings.Contains(css.String(), ".chroma") {
+		t.Errorf("expected css for the chroma classes, got %s", css.String())
+	}
+	if err := writeHighlightCSS(&css, "nope"); err == nil {
+		t.Errorf("expected an error for an unknown style")
+	}
+	fp := filepath.Join(t.TempDir(), ".squatch")
+	os.WriteFile(fp, []byte(`{"highlight": {"style": "nope"}}`), 0644)
+	if _, err := getSquatchConfig(fp); err == nil {
+		t.Errorf("expected getSquatchConfig to reject unknown styles")
+	}
+}
@@ -0,0 +1,258 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// extendsDirective matches the comment at the start of a layout naming the
+// layout it extends, like {{/* extends "article" */}}
+var extendsDirective = regexp.MustCompile(`^\s*\{\{-?\s*/\*\s*extends\s+"([^"]*)"\s*\*/\s*-?\}\}`)
+
+// layoutParent returns the name of the layout that layout extends, or an
+// empty string if it doesn't extend another layout
+func layoutParent(layout string) string {
+	match := extendsDirective.FindStringSubmatch(layout)
+	if match == nil {
+		return ""
+	}
+	return match[1]
+}
+
+// defaultLayout is used by pages that don't set a layout
+const defaultLayout = "default"
+
+// relDir returns the folder of the file at fp relative to the source
+// directory, with slashes. The source directory itself is "".
+func (app App) relDir(fp string) string {
+	rel, err := filepath.Rel(app.SrcDir, filepath.Dir(fp))
+	if err != nil || rel == "." {
+		return ""
+	}
+	return filepath.ToSlash(rel)
+}
+
+// parentDir returns the folder above dir, or "" for the source directory
+func parentDir(dir string) string {
+	parent := path.Dir(dir)
+	if parent == "." || parent == "/" {
+		return ""
+	}
+	return parent
+}
+
+// layoutKey returns the key in App.Layouts of the layout name in dir
+func layoutKey(dir string, name string) string {
+	return path.Join(dir, name)
+}
+
+// resolveLayout returns the key of the layout name in dir, or in the closest
+// folder above dir that has one
+func (app App) resolveLayout(dir string, name string) (string, bool) {
+	if name == "" {
+		return "", false
+	}
+	for {
+		key := layoutKey(dir, name)
+		if _, ok := app.Layouts[key]; ok {
+			return key, true
+		}
+		if dir == "" {
+			return "", false
+		}
+		dir = parentDir(dir)
+	}
+}
+
+// pageDir returns the folder layouts are looked up from for page. Generated
+// pages like section lists and taxonomy pages use their section's folder.
+func (app App) pageDir(page Page) string {
+	if page.Filepath != "" {
+		return app.relDir(page.Filepath)
+	}
+	return page.Section
+}
+
+// pageLayout returns the key of the layout page is rendered with
+func (app App) pageLayout(page Page) (string, bool) {
+	return app.resolveLayout(app.pageDir(page), page.Layout)
+}
+
+// The missingLayout policies for pages whose layout can't be found
+const (
+	skipMissingLayout    = "skip"
+	defaultMissingLayout = "default"
+	failMissingLayout    = "fail"
+)
+
+// checkMissingLayout returns an error for unknown missingLayout policies
+func checkMissingLayout(policy string) error {
+	switch policy {
+	case "", skipMissingLayout, defaultMissingLayout, failMissingLayout:
+		return nil
+	}
+	return fmt.Errorf("unknown missingLayout policy %q", policy)
+}
+
+// missingLayout applies the missingLayout policy to a page whose layout
+// can't be found. It returns the page to render and false if the page is
+// skipped instead. Pages at fp are skipped with a warning by default, fall
+// back to the default layout with a warning or fail the build.
+func (app App) missingLayout(fp string, page Page) (Page, bool, error) {
+	missing := fmt.Errorf("could not find layout %v", page.Layout)
+	switch app.Config.MissingLayout {
+	case failMissingLayout:
+		return page, false, BuildError{Path: fp, Err: missing}
+	case defaultMissingLayout:
+		page.Layout = defaultLayout
+		if _, ok := app.pageLayout(page); ok {
+			app.warn(fp, fmt.Errorf("%v, using the default layout", missing))
+			return page, true, nil
+		}
+	}
+	app.warn(fp, fmt.Errorf("%v, skipped the page", missing))
+	return page, false, nil
+}
+
+// siteTemplate returns the closest layout.html to dir and its path relative
+// to the source directory
+func (app App) siteTemplate(dir string) (string, string) {
+	for dir != "" {
+		if t, ok := app.SiteTemplates[dir]; ok {
+			return t, path.Join(dir, "layout.html")
+		}
+		dir = parentDir(dir)
+	}
+	return app.SiteTemplate, "layout.html"
+}
+
+// layoutFile returns the path of the layout at key relative to the source
+// directory
+func layoutFile(key string) string {
+	return path.Join(parentDir(key), "layout_"+path.Base(key)+".html")
+}
+
+// layoutParentKey returns the key of the layout the layout at key extends.
+// The parent is looked up from the layout's folder, so a layout can extend
+// the layout of the same name in a folder above it.
+func (app App) layoutParentKey(key string) (string, string, bool) {
+	name := layoutParent(app.Layouts[key])
+	if name == "" {
+		return "", "", false
+	}
+	dir := parentDir(key)
+	parent, ok := app.resolveLayout(dir, name)
+	if parent == key {
+		if dir == "" {
+			return name, "", false
+		}
+		parent, ok = app.resolveLayout(parentDir(dir), name)
+	}
+	return name, parent, ok
+}
+
+// layoutChain returns the layout key followed by every layout it extends
+func (app App) layoutChain(key string) ([]string, error) {
+	if _, ok := app.Layouts[key]; !ok {
+		return nil, fmt.Errorf("could not find layout %v", key)
+	}
+	chain := []string{key}
+	seen := map[string]bool{key: true}
+	for {
+		name, parent, ok := app.layoutParentKey(key)
+		if name == "" {
+			return chain, nil
+		}
+		if !ok {
+			return chain, fmt.Errorf("could not find layout %v extended by %v", name, key)
+		}
+		if seen[parent] {
+			return chain, fmt.Errorf("layout %v extends itself", parent)
+		}
+		seen[parent] = true
+		chain = append(chain, parent)
+		key = parent
+	}
+}
+
+// usesLayout reports whether the layout key is layout or one of the layouts
+// it extends
+func (app App) usesLayout(layout string, key string) bool {
+	chain, _ := app.layoutChain(layout)
+	for _, used := range chain {
+		if used == key {
+			return true
+		}
+	}
+	return false
+}
+
+// partialName returns the template name of the partial defined by the file at
+// path and whether the file is a partial at all. Partials are named after
+// their file, like partial_header.html.
+func partialName(path string) (string, bool) {
+	base := filepath.Base(path)
+	if filepath.Ext(base) == ".html" && strings.HasPrefix(base, "partial_") {
+		return base, true
+	}
+	return "", false
+}
+
+// loadPartial reads the partial at path into the app
+func (app *App) loadPartial(path string) error {
+	partialByte, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	name, _ := partialName(path)
+	app.Partials[name] = string(partialByte)
+	app.PartialPaths[name] = path
+	return nil
+}
+
+// layoutTemplates parses the site template, the partials and the chain of
+// layouts of page into a single template set. Layouts are parsed after the
+// layouts they extend so their definitions replace the blocks of those
+// layouts. It returns the set, which executes the site template, and the name
+// of the template to execute for the page content. Sets are parsed once and
+// cached for the other pages using the same templates.
+func (app App) layoutTemplates(page Page) (pageTemplate, string, error) {
+	layout, ok := app.pageLayout(page)
+	if !ok {
+		return nil, "", fmt.Errorf("could not find layout %v", page.Layout)
+	}
+	site, siteFile := app.siteTemplate(app.pageDir(page))
+	parse := func() (pageTemplate, string, error) {
+		return app.parseLayoutTemplates(site, siteFile, layout)
+	}
+	if app.TemplateCache == nil {
+		return parse()
+	}
+	return app.TemplateCache.get(templateKey{site: siteFile, layout: layout}, parse)
+}
+
+// parseLayoutTemplates parses the site template in siteFile, the partials and
+// the layout at key with the layouts it extends
+func (app App) parseLayoutTemplates(site string, siteFile string, key string) (pageTemplate, string, error) {
+	chain, err := app.layoutChain(key)
+	if err != nil {
+		return nil, "", err
+	}
+	// Templates are named after their files so errors point at them
+	sources := []templateSource{{name: siteFile, text: site}}
+	for name, partial := range app.Partials {
+		sources = append(sources, templateSource{name: name, text: partial})
+	}
+	for i := len(chain) - 1; i >= 0; i-- {
+		sources = append(sources, templateSource{name: layoutFile(chain[i]), text: app.Layouts[chain[i]]})
+	}
+	t, err := app.parseTemplates(sources)
+	if err != nil {
+		return nil, "", err
+	}
+	return t, layoutFile(chain[len(chain)-1]), nil
+}
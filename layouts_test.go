@@ -0,0 +1,220 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestLayoutParent(t *testing.T) {
+	tests := []struct {
+		layout   string
+		expected string
+	}{
+		{`{{/* extends "article" */}}`, "article"},
+		{"\n{{- /* extends \"base\" */ -}}\n{{define \"content\"}}{{end}}", "base"},
+		{`<div>{{/* extends "article" */}}</div>`, ""},
+		{`{{.Body}}`, ""},
+	}
+	for _, test := range tests {
+		if got := layoutParent(test.layout); got != test.expected {
+			t.Errorf("layoutParent(%q) = %q, expected %q", test.layout, got, test.expected)
+		}
+	}
+}
+
+func TestResolveLayout(t *testing.T) {
+	app := App{SrcDir: "src", Layouts: map[string]string{
+		"pages":            "root pages",
+		"docs/pages":       "docs pages",
+		"docs/guide/other": "other",
+	}}
+	tests := []struct {
+		dir      string
+		name     string
+		expected string
+		ok       bool
+	}{
+		{"", "pages", "pages", true},
+		{"blog", "pages", "pages", true},
+		{"docs", "pages", "docs/pages", true},
+		{"docs/guide/step", "pages", "docs/pages", true},
+		{"docs/guide", "other", "docs/guide/other", true},
+		{"docs", "other", "", false},
+		{"docs", "", "", false},
+	}
+	for _, test := range tests {
+		key, ok := app.resolveLayout(test.dir, test.name)
+		if key != test.expected || ok != test.ok {
+			t.Errorf("resolveLayout(%q, %q) = %q, %v, expected %q, %v", test.dir, test.name, key, ok, test.expected, test.ok)
+		}
+	}
+	page := Page{Filepath: filepath.Join("src", "docs", "a.md"), Layout: "pages"}
+	if key, _ := app.pageLayout(page); key != "docs/pages" {
+		t.Errorf("expected the page to use docs/pages, got %v", key)
+	}
+}
+
+func TestSiteTemplate(t *testing.T) {
+	app := App{SiteTemplate: "root", SiteTemplates: map[string]string{"docs": "docs"}}
+	tests := map[string]string{"": "root", "blog": "root", "docs": "docs", "docs/guide": "docs"}
+	for dir, expected := range tests {
+		if got, _ := app.siteTemplate(dir); got != expected {
+			t.Errorf("siteTemplate(%q) = %q, expected %q", dir, got, expected)
+		}
+	}
+}
+
+func TestLayoutChain(t *testing.T) {
+	app := App{Layouts: map[string]string{
+		"post":      `{{/* extends "article" */}}`,
+		"article":   `{{/* extends "base" */}}`,
+		"base":      `{{block "content" .}}{{end}}`,
+		"loop":      `{{/* extends "loop" */}}`,
+		"orphan":    `{{/* extends "missing" */}}`,
+		"docs/post": `{{/* extends "post" */}}`,
+	}}
+	chain, err := app.layoutChain("post")
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if !reflect.DeepEqual(chain, []string{"post", "article", "base"}) {
+		t.Errorf("unexpected chain %v", chain)
+	}
+	for _, name := range []string{"loop", "orphan", "missing"} {
+		if _, err := app.layoutChain(name); err == nil {
+			t.Errorf("expected an error for layout %v", name)
+		}
+	}
+	chain, err = app.layoutChain("docs/post")
+	if err != nil || !reflect.DeepEqual(chain, []string{"docs/post", "post", "article", "base"}) {
+		t.Errorf("expected docs/post to extend the root post, got %v, %v", chain, err)
+	}
+	if !app.usesLayout("post", "base") || app.usesLayout("article", "post") {
+		t.Errorf("expected post to use base and article not to use post")
+	}
+}
+
+func TestRenderNestedLayouts(t *testing.T) {
+	dist := t.TempDir()
+	app := App{
+		SrcDir:       "src",
+		DistDir:      dist,
+		SiteTemplate: `<title>{{block "title" .}}{{.Title}}{{end}}</title><main>{{.Body}}</main>`,
+		Layouts: map[string]string{
+			"article": `<article>{{block "content" .}}{{.Body}}{{end}}</article>{{template "partial_footer.html" .}}`,
+			"post":    `{{/* extends "article" */}}{{define "title"}}Post: {{.Title}}{{end}}{{define "content"}}<p class="post">{{.Body}}</p>{{end}}`,
+		},
+		Partials: map[string]string{"partial_footer.html": `<footer>{{.Title}}</footer>`},
+	}
+	page := Page{Title: "Hello", Body: "text", Layout: "post", Filepath: filepath.Join("src", "hello.md")}
+	if err := app.renderPage(page); err != nil {
+		t.Fatalf("expected renderPage to return no error, got %v", err)
+	}
+	data, err := os.ReadFile(filepath.Join(dist, "hello.html"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `<title>Post: Hello</title><main><article><p class="post">text</p></article><footer>Hello</footer></main>`
+	if string(data) != expected {
+		t.Errorf("Expected: %s, got: %s", expected, string(data))
+	}
+}
+
+func TestBuildNestedLayouts(t *testing.T) {
+	srcTest := "src_test"
+	defer cleanup("dist")
+	Build(srcTest)
+	data, err := os.ReadFile(filepath.Join("dist", "pages", "guide.html"))
+	if err != nil {
+		t.Fatalf("expected guide.html to exist, got %v", err)
+	}
+	body := string(data)
+	for _, expected := range []string{`<h1 class="guide">Guide</h1>`, "<p>Follow the steps.</p>", `<footer class="article-footer">Squatch Test</footer>`} {
+		if !strings.Contains(body, expected) {
+			t.Errorf("expected guide.html to contain %s, got %v", expected, body)
+		}
+	}
+	if _, err := os.Stat(filepath.Join("dist", "partial_footer.html")); err == nil {
+		t.Errorf("expected partials not to be copied to dist")
+	}
+}
+
+func TestBuildDirectoryLayouts(t *testing.T) {
+	srcTest := "src_test"
+	defer cleanup("dist")
+	Build(srcTest)
+	for _, rel := range []string{filepath.Join("nested", "intro.html"), filepath.Join("nested", "deeper", "detail.html")} {
+		data, err := os.ReadFile(filepath.Join("dist", rel))
+		if err != nil {
+			t.Fatalf("expected %v to exist, got %v", rel, err)
+		}
+		if !strings.HasPrefix(string(data), `<html><body id="nested-site"><div class="nested-default">`) {
+			t.Errorf("expected %v to use the nested site template and default layout, got %v", rel, string(data))
+		}
+	}
+	data, err := os.ReadFile(filepath.Join("dist", "index.html"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), "nested-site") {
+		t.Errorf("expected the root pages to keep the root site template")
+	}
+}
+
+func TestMissingLayoutPolicy(t *testing.T) {
+	files := func() map[string]string {
+		return map[string]string{
+			"layout.html":         "{{.Body}}",
+			"layout_default.html": `<div class="default">{{.Body}}</div>`,
+			"typo.md":             "---\ntitle: Typo\nlayout: pgae\n---\nHello",
+		}
+	}
+	tests := []struct {
+		policy   string
+		output   string
+		failed   bool
+		expected string
+	}{
+		{"", "", false, "could not find layout pgae, skipped the page"},
+		{skipMissingLayout, "", false, "could not find layout pgae, skipped the page"},
+		{defaultMissingLayout, `<div class="default"><p>Hello</p>`, false, "could not find layout pgae, using the default layout"},
+		{failMissingLayout, "", true, "could not find layout pgae"},
+	}
+	for _, test := range tests {
+		config := ""
+		if test.policy != "" {
+			config = `"missingLayout": "` + test.policy + `"`
+		}
+		srcDir := writeSite(t, config, files())
+		err := build(srcDir, false)
+		if (err != nil) != test.failed {
+			t.Errorf("%q: expected the build to fail: %v, got %v", test.policy, test.failed, err)
+		}
+		app, _ := InitApp(srcDir)
+		app.renderSite()
+		if len(app.Report.Problems) != 1 || !strings.HasSuffix(app.Report.Problems[0].Error(), test.expected) {
+			t.Errorf("%q: expected the problem %q, got %v", test.policy, test.expected, app.Report.Problems)
+		}
+		data, err := os.ReadFile(filepath.Join(filepath.Dir(srcDir), "dist", "typo.html"))
+		if test.output == "" && err == nil {
+			t.Errorf("%q: expected typo.html not to be built, got %s", test.policy, data)
+		}
+		if test.output != "" && !strings.HasPrefix(string(data), test.output) {
+			t.Errorf("%q: expected typo.html to start with %v, got %s", test.policy, test.output, data)
+		}
+	}
+}
+
+func TestCheckMissingLayout(t *testing.T) {
+	for _, policy := range []string{"", "skip", "default", "fail"} {
+		if err := checkMissingLayout(policy); err != nil {
+			t.Errorf("expected %q to be valid, got %v", policy, err)
+		}
+	}
+	if err := checkMissingLayout("ignore"); err == nil {
+		t.Errorf("expected an unknown policy to be an error")
+	}
+}
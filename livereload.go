@@ -0,0 +1,124 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"sync"
+)
+
+// liveReloadPath is the endpoint browsers listen on for rebuild notifications
+const liveReloadPath = "/__squatch/reload"
+
+// liveReloadScript is injected into every html page served by the live server.
+// A "reload" event reloads the page and a "css" event swaps the stylesheets
+// in place by busting their cache.
+const liveReloadScript = `<script>
+(function() {
+	var source = new EventSource("` + liveReloadPath + `");
+	source.addEventListener("reload", function() {
+		location.reload();
+	});
+	source.addEventListener("css", function() {
+		document.querySelectorAll('link[rel="stylesheet"]').forEach(function(link) {
+			var url = new URL(link.href);
+			url.searchParams.set("squatch", Date.now());
+			link.href = url.toString();
+		});
+	});
+})();
+</script>
+`
+
+// Events sent to the browser after a build
+const (
+	reloadEvent = "reload"
+	cssEvent    = "css"
+)
+
+// liveReload keeps track of the connected browsers and notifies them after
+// each successful build
+type liveReload struct {
+	mu      sync.Mutex
+	clients map[chan string]bool
+}
+
+func newLiveReload() *liveReload {
+	return &liveReload{clients: make(map[chan string]bool)}
+}
+
+func (l *liveReload) subscribe() chan string {
+	ch := make(chan string, 1)
+	l.mu.Lock()
+	l.clients[ch] = true
+	l.mu.Unlock()
+	return ch
+}
+
+func (l *liveReload) unsubscribe(ch chan string) {
+	l.mu.Lock()
+	delete(l.clients, ch)
+	l.mu.Unlock()
+}
+
+// notify sends event to every connected browser. Browsers that have not read
+// the previous event yet are skipped since they are about to reload anyway.
+func (l *liveReload) notify(event string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for ch := range l.clients {
+		select {
+		case ch <- event:
+		default:
+		}
+	}
+}
+
+// ServeHTTP streams build notifications to the browser as server-sent events
+func (l *liveReload) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+
+	ch := l.subscribe()
+	defer l.unsubscribe(ch)
+
+	fmt.Fprint(w, ": connected\n\n")
+	flusher.Flush()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case event := <-ch:
+			fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, event)
+			flusher.Flush()
+		}
+	}
+}
+
+// reloadEventFor returns the event to send after a change to fp. Stylesheets
+// can be swapped without reloading the page.
+func reloadEventFor(fp string) string {
+	if filepath.Ext(fp) == ".css" {
+		return cssEvent
+	}
+	return reloadEvent
+}
+
+// injectLiveReload adds the live reload script to the end of the page body
+func injectLiveReload(page []byte) []byte {
+	i := bytes.LastIndex(bytes.ToLower(page), []byte("</body>"))
+	if i < 0 {
+		return append(page, []byte(liveReloadScript)...)
+	}
+	injected := make([]byte, 0, len(page)+len(liveReloadScript))
+	injected = append(injected, page[:i]...)
+	injected = append(injected, liveReloadScript...)
+	return append(injected, page[i:]...)
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"bufio"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestInjectLiveReload(t *testing.T) {
+	page := injectLiveReload([]byte("<html><body><p>hi</p></BODY></html>"))
+	expected := "<html><body><p>hi</p>" + liveReloadScript + "</BODY></html>"
+	if string(page) != expected {
+		t.Errorf("expected %s, got %s", expected, string(page))
+	}
+	page = injectLiveReload([]byte("<p>fragment</p>"))
+	if string(page) != "<p>fragment</p>"+liveReloadScript {
+		t.Errorf("expected script to be appended, got %s", string(page))
+	}
+}
+
+func TestReloadEventFor(t *testing.T) {
+	if reloadEventFor("src/static/main.css") != cssEvent {
+		t.Errorf("expected css event for stylesheets")
+	}
+	if reloadEventFor("src/index.md") != reloadEvent {
+		t.Errorf("expected reload event for pages")
+	}
+}
+
+func TestLiveReloadNotify(t *testing.T) {
+	reload := newLiveReload()
+	server := httptest.NewServer(reload)
+	defer server.Close()
+
+	res, err := http.Get(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	if res.Header.Get("Content-Type") != "text/event-stream" {
+		t.Fatalf("expected text/event-stream, got %s", res.Header.Get("Content-Type"))
+	}
+
+	// Wait for the browser to be registered before sending the event
+	reader := bufio.NewReader(res.Body)
+	if _, err := reader.ReadString('\n'); err != nil {
+		t.Fatal(err)
+	}
+	reload.notify(cssEvent)
+
+	done := make(chan string)
+	go func() {
+		for {
+			line, err := reader.ReadString('\n')
+			if err != nil || strings.HasPrefix(line, "event: ") {
+				done <- strings.TrimSpace(strings.TrimPrefix(line, "event: "))
+				return
+			}
+		}
+	}()
+	select {
+	case event := <-done:
+		if event != cssEvent {
+			t.Fatalf("expected %s event, got %s", cssEvent, event)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for event")
+	}
+}
@@ -4,171 +4,359 @@ import (
 	"bytes"
 	"flag"
 	"fmt"
+	"html/template"
 	"io"
 	"os"
 	"path/filepath"
 	"strings"
-	"text/template"
-
-	"github.com/gomarkdown/markdown"
-	"github.com/gomarkdown/markdown/html"
+	"time"
 )
 
 type App struct {
-	SiteTemplate  string
+	// SiteTemplate is the layout.html at the root of the source directory
+	SiteTemplate string
+	// SiteTemplates maps subfolders to the layout.html in them
+	SiteTemplates map[string]string
 	SrcDir        string
 	DistDir       string
+	// Layouts maps layout keys to their templates. Layouts at the root are
+	// keyed by name and layouts in a subfolder by folder/name.
 	Layouts       map[string]string
 	Pages         []Page
 	IgnoreFolders map[string]bool
 	IgnoreFiles   map[string]bool
 	ThemeConfig   ThemeConfig
+	Config        SquatchConfig
+	BuildTime     time.Time
+	Sections      map[string][]Page
+	Taxonomies    map[string][]Term
+	// Shortcodes maps shortcode names to their templates
+	Shortcodes map[string]string
+	// Partials maps partial file names to their templates and PartialPaths
+	// to the files they were read from
+	Partials     map[string]string
+	PartialPaths map[string]string
+	// Report collects the errors and warnings of the current build
+	Report *BuildReport
+	// TemplateCache holds the parsed layouts shared by pages
+	TemplateCache *templateCache
 }
 
 type Page struct {
-	Title    string
-	Body     string
+	Title string
+	// Body is the rendered html of the page, which html templates don't escape
+	Body     template.HTML
 	Layout   string
 	Filepath string
+	// URL is the path of the rendered page from the site root
+	URL string
+	// Params holds every field from the page's front matter
+	Params map[string]interface{}
+	// Date comes from the date param or a YYYY-MM-DD- filename prefix
+	Date time.Time
+	// TOC is the table of contents of the page's headings
+	TOC TOC
+	// Section is the configured section folder the page is in, or the
+	// taxonomy folder of a taxonomy page
+	Section string
+	// Prev and Next are the older and newer posts in a posts section
+	Prev *Page
+	Next *Page
+	// Paginator is set on the generated section list pages
+	Paginator *Paginator
+	// Terms is set on taxonomy pages and Term on term pages
+	Terms []Term
+	Term  *Term
+	// Site is set while rendering so templates can use .Site
+	Site *Site
+}
+
+// Site is the site wide data available to every template as .Site
+type Site struct {
+	Title      string
+	Author     string
+	Params     map[string]interface{}
+	Pages      []Page
+	Sections   map[string][]Page
+	Taxonomies map[string][]Term
+	BuildTime  time.Time
 }
 
+// InvalidPageError is returned for markdown files that are not pages
 type InvalidPageError struct {
 	s string
+	// Excluded is set for pages that opted out of the build with build: false
+	Excluded bool
 }
 
 func (e InvalidPageError) Error() string {
 	return e.s
 }
 
-func check(e error) {
-	if e != nil {
-		panic(e)
-	}
-}
-
 func (app App) getPage(fp string) (Page, error) {
-	page := Page{Filepath: fp}
+	page := Page{Filepath: fp, URL: app.pageURL(fp)}
 	// read the markdown file
 	md, err := os.ReadFile(fp)
 	if err != nil {
-		fmt.Println("Could not read file: ", fp)
 		return page, err
 	}
 
-	lines := strings.Split(string(md), "\n")
-	contentStart := 0
-	if len(lines) > 0 && strings.TrimSpace(lines[0]) == "---" {
-		for i := 1; i < len(lines); i++ {
-			if strings.TrimSpace(lines[i]) == "---" {
-				fm := lines[1:i]
-				for _, l := range fm {
-					l = strings.TrimSpace(l)
-					if strings.HasPrefix(l, "title:") {
-						page.Title = strings.TrimSpace(strings.TrimPrefix(l, "title:"))
-					} else if strings.HasPrefix(l, "layout:") {
-						page.Layout = strings.TrimSpace(strings.TrimPrefix(l, "layout:"))
-					}
-				}
-				contentStart = i + 1
-				break
-			}
-		}
+	delimiter, matter, content := splitFrontMatter(string(md))
+	page.Params, err = parseFrontMatter(delimiter, matter)
+	if err != nil && namesPage(matter) {
+		return page, frontMatterError(fp, err)
+	} else if err != nil {
+		// The block is just content between two horizontal rules
+		page.Params, content = map[string]interface{}{}, string(md)
 	}
-
-	for _, line := range lines[contentStart:] {
-		if strings.HasPrefix(line, "[_metadata_:title]:- \"") {
-			title := strings.TrimPrefix(line, "[_metadata_:title]:- \"")
-			page.Title = strings.TrimSuffix(title, "\"")
-		}
-		if strings.HasPrefix(line, "[_metadata_:layout]:- \"") {
-			layout := strings.TrimPrefix(line, "[_metadata_:layout]:- \"")
-			page.Layout = strings.TrimSuffix(layout, "\"")
-		}
-	}
-
-	// render the markdown file (without frontmatter)
-	bodyLines := lines[contentStart:]
-	mdBody := strings.Join(bodyLines, "\n")
-	opts := html.RendererOptions{
-		Flags:          html.FlagsNone,
-		RenderNodeHook: app.renderHook,
+	parseMetadata(content, page.Params)
+	page.Title = stringParam(page.Params, "title")
+	page.Layout = stringParam(page.Params, "layout")
+	if _, ok := app.resolveLayout(app.relDir(fp), defaultLayout); ok && page.Layout == "" {
+		page.Layout = defaultLayout
 	}
-	renderer := html.NewRenderer(opts)
-	page.Body = string(markdown.ToHTML([]byte(mdBody), nil, renderer))
+	page.Date = pageDate(fp, page.Params)
 
 	// If the page metadata cannot be found, return an error to skip the page
 	// This is useful for markdown that are not pages
+	if page.Params["build"] == false {
+		return page, InvalidPageError{s: "excluded with build: false", Excluded: true}
+	}
 	if page.Title == "" {
-		return page, InvalidPageError{s: fmt.Sprintf("no title found in %v", fp)}
+		return page, InvalidPageError{s: "no title found"}
 	}
 	if page.Layout == "" {
-		return page, InvalidPageError{s: fmt.Sprintf("no layout found in %v", fp)}
+		return page, InvalidPageError{s: "no layout found"}
+	}
+
+	content, err = app.expandShortcodes(content, page)
+	if err != nil {
+		return page, BuildError{Path: fp, Err: err}
 	}
+
+	// render the markdown file (without frontmatter)
+	body, toc := app.renderMarkdown([]byte(content))
+	page.Body = template.HTML(body)
+	page.TOC = toc
 	return page, nil
 }
 
-func (app App) renderPage(page Page) (err error) {
-	innerLayout, ok := app.Layouts[page.Layout]
-	if !ok {
-		// Skip the page if the layout is not found
-		fmt.Printf("Could not find layout %v for page %v", page.Layout, page.Filepath)
-		return nil
+// site returns the site wide template data
+func (app App) site() *Site {
+	return &Site{
+		Title:      app.Config.Title,
+		Author:     app.Config.Author,
+		Params:     app.Config.Params,
+		Pages:      app.Pages,
+		Sections:   app.Sections,
+		Taxonomies: app.Taxonomies,
+		BuildTime:  app.BuildTime,
 	}
+}
 
-	// Parse inner template
-	t := template.New("page")
-	t, err = t.Parse(innerLayout)
+func (app App) renderPage(page Page) error {
+	newFilePath, err := app.pageDistPath(page.Filepath)
 	if err != nil {
-		fmt.Printf("error parsing template file at %v: %v\n", app.Layouts[page.Layout], err)
 		return err
 	}
-	t = template.Must(t, err)
+	return app.renderPageTo(page, newFilePath)
+}
+
+// renderPageTo renders page through its layout and the site template and
+// writes the result to newFilePath. Pages whose layout can't be found are
+// handled by the missingLayout policy.
+func (app App) renderPageTo(page Page, newFilePath string) (err error) {
+	page.Site = app.site()
+	// Generated pages like section lists have no source file
+	source := page.Filepath
+	if source == "" {
+		source = page.URL
+	}
+	if _, ok := app.pageLayout(page); !ok {
+		page, ok, err = app.missingLayout(source, page)
+		if !ok || err != nil {
+			return err
+		}
+	}
+
+	t, root, err := app.layoutTemplates(page)
+	if err != nil {
+		return app.templateError(source, err)
+	}
+	// Execute the layouts, then the site template around them
 	var inner bytes.Buffer
-	err = t.Execute(&inner, page)
+	err = t.ExecuteTemplate(&inner, root, page)
+	if err != nil {
+		return app.templateError(source, err)
+	}
+	page.Body = template.HTML(inner.String())
+	var processed bytes.Buffer
+	err = t.Execute(&processed, page)
 	if err != nil {
-		fmt.Println("error executing template: ", err)
+		return app.templateError(source, err)
+	}
+
+	// write the page to a file
+	if err := os.MkdirAll(filepath.Dir(newFilePath), 0755); err != nil {
 		return err
 	}
-	page.Body = inner.String()
+	return os.WriteFile(newFilePath, processed.Bytes(), 0644)
+}
 
-	// Parse outer template
-	t = template.New("Render")
-	t, err = t.Parse(app.SiteTemplate)
+// pageDistPath returns the path a markdown page is rendered to
+func (app App) pageDistPath(fp string) (string, error) {
+	relpath, err := filepath.Rel(app.SrcDir, fp)
+	if err != nil {
+		return "", err
+	}
+	relpath = strings.TrimSuffix(relpath, filepath.Ext(relpath)) + ".html"
+	return filepath.Join(app.DistDir, relpath), nil
+}
+
+// pageURL returns the url a markdown page is served at. Index pages are
+// served at their folder.
+func (app App) pageURL(fp string) string {
+	relpath, err := filepath.Rel(app.SrcDir, fp)
+	if err != nil {
+		return ""
+	}
+	relpath = filepath.ToSlash(strings.TrimSuffix(relpath, filepath.Ext(relpath)))
+	if relpath == "index" {
+		return "/"
+	}
+	if strings.HasSuffix(relpath, "/index") {
+		return "/" + strings.TrimSuffix(relpath, "index")
+	}
+	return "/" + relpath + ".html"
+}
+
+// renderSite renders every page and the generated files like feeds
+func (app App) renderSite() error {
+	err := app.renderPages()
 	if err != nil {
-		fmt.Println("Could not parse template: ", err)
 		return err
 	}
-	t = template.Must(t, err)
-	var processed bytes.Buffer
-	err = t.Execute(&processed, page)
+	return app.renderGenerated()
+}
+
+// renderGenerated writes the files generated from the list of pages
+func (app App) renderGenerated() error {
+	err := app.renderFeeds()
+	if err != nil {
+		return err
+	}
+	err = app.renderSitemap()
+	if err != nil {
+		return err
+	}
+	err = app.renderRobots()
 	if err != nil {
-		fmt.Println("Could not execute template: ", err)
 		return err
 	}
+	return app.renderSearch()
+}
 
-	// write the page to a file
-	relpath, err := filepath.Rel(app.SrcDir, page.Filepath)
+// renderPages renders every page, section listing and taxonomy page to the
+// dist directory
+func (app App) renderPages() error {
+	err := forEach(len(app.Pages), func(i int) error {
+		return app.recordError(app.Pages[i].Filepath, app.renderPage(app.Pages[i]))
+	})
+	if err != nil {
+		return err
+	}
+	err = app.renderSectionLists()
+	if err != nil {
+		return err
+	}
+	return app.renderTaxonomies()
+}
+
+// copyFile copies a file from the source directory to the same place in the
+// dist directory
+func (app App) copyFile(path string) error {
+	relpath, err := filepath.Rel(app.SrcDir, path)
 	if err != nil {
-		fmt.Println("Could not get relative path: ", err)
 		return err
 	}
-	newFilePath := relpath[:len(relpath)-3] + ".html"
-	newFilePath = filepath.Join(app.DistDir, newFilePath)
+	newFilePath := filepath.Join(app.DistDir, relpath)
 	if err := os.MkdirAll(filepath.Dir(newFilePath), 0755); err != nil {
-		fmt.Println("Could not create directory: ", err)
 		return err
 	}
-	err = os.WriteFile(newFilePath, processed.Bytes(), 0644)
+	source, err := os.Open(path)
 	if err != nil {
-		fmt.Println("Could not write file: ", err)
 		return err
 	}
+	defer source.Close()
+	destination, err := os.Create(newFilePath)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(destination, source)
+	if closeErr := destination.Close(); err == nil {
+		err = closeErr
+	}
 	return err
 }
 
+// layoutName returns the name of the layout defined by the file at path and
+// whether the file is a layout at all. The site template has an empty name.
+func layoutName(path string) (string, bool) {
+	base := filepath.Base(path)
+	if base == "layout.html" {
+		return "", true
+	}
+	if filepath.Ext(base) == ".html" && strings.HasPrefix(base, "layout_") {
+		name := strings.TrimSuffix(base, ".html")
+		return strings.TrimPrefix(name, "layout_"), true
+	}
+	return "", false
+}
+
+// loadLayout reads the layout at path into the app
+func (app *App) loadLayout(path string) error {
+	layoutByte, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	name, _ := layoutName(path)
+	dir := app.relDir(path)
+	if name != "" {
+		app.Layouts[layoutKey(dir, name)] = string(layoutByte)
+	} else if dir != "" {
+		app.SiteTemplates[dir] = string(layoutByte)
+	} else {
+		app.SiteTemplate = string(layoutByte)
+	}
+	return nil
+}
+
+// isIgnoredDir reports whether the folder at path is skipped when building
+func (app App) isIgnoredDir(path string) bool {
+	if path == app.SrcDir {
+		return false
+	}
+	if filepath.Clean(path) == filepath.Clean(app.DistDir) {
+		return true
+	}
+	name := filepath.Base(path)
+	if _, ok := app.IgnoreFolders[name]; ok {
+		return true
+	}
+	return strings.HasPrefix(name, ".")
+}
+
 func (app *App) parseSrcDirectory() error {
 	app.Layouts = make(map[string]string)
+	app.SiteTemplates = make(map[string]string)
+	app.Shortcodes = make(map[string]string)
+	app.Partials = make(map[string]string)
+	app.PartialPaths = make(map[string]string)
+	app.TemplateCache = newTemplateCache()
 	app.Pages = make([]Page, 0)
+	// Pages are read after the walk so every shortcode is loaded first
+	pagePaths := []string{}
+	copyPaths := []string{}
 	err := filepath.Walk(app.SrcDir, func(path string, info os.FileInfo, err error) error {
 		if err != nil {
 			return err
@@ -176,10 +364,7 @@ func (app *App) parseSrcDirectory() error {
 
 		// Ignore directories and files
 		if info.IsDir() {
-			if _, ok := app.IgnoreFolders[info.Name()]; ok {
-				return filepath.SkipDir
-			}
-			if strings.HasPrefix(info.Name(), ".") {
+			if app.isIgnoredDir(path) {
 				return filepath.SkipDir
 			}
 			return nil
@@ -193,67 +378,53 @@ func (app *App) parseSrcDirectory() error {
 
 		// parse the layouts
 		ext := filepath.Ext(path)
-		base := filepath.Base(path)
-		if base == "layout.html" {
-			layoutByte, err := os.ReadFile(path)
-			if err != nil {
-				fmt.Println("Could not read file: ", path)
-				return err
-			}
-			app.SiteTemplate = string(layoutByte)
-		} else if ext == ".html" && strings.HasPrefix(base, "layout_") {
-			name := filepath.Base(path)
-			name = strings.TrimSuffix(name, ".html")
-			name = strings.TrimPrefix(name, "layout_")
-			layoutByte, err := os.ReadFile(path)
-			if err != nil {
-				fmt.Printf("error reading template file at %v: %v\n", path, err)
-				return err
-			}
-			app.Layouts[name] = string(layoutByte)
+		if _, ok := layoutName(path); ok {
+			return app.recordError(path, app.loadLayout(path))
+		} else if _, ok := shortcodeName(path); ok {
+			return app.recordError(path, app.loadShortcode(path))
+		} else if _, ok := partialName(path); ok {
+			return app.recordError(path, app.loadPartial(path))
 		} else if ext == ".md" {
-			page, err := app.getPage(path)
-			// Skip pages we can't read because they could be README, LICENSE, drafts, etc.
-			if _, ok := err.(InvalidPageError); ok {
-				return nil
-			} else if err != nil {
-				fmt.Println("Could not read file: ", path)
-				return err
-			}
-			app.Pages = append(app.Pages, page)
+			pagePaths = append(pagePaths, path)
 		} else {
 			// Copy any other file to the dist directory
-			relpath, err := filepath.Rel(app.SrcDir, path)
-			if err != nil {
-				fmt.Println("Could not get relative path: ", err)
-				return err
-			}
-			newFilePath := filepath.Join(app.DistDir, relpath)
-			if err := os.MkdirAll(filepath.Dir(newFilePath), 0755); err != nil {
-				fmt.Println("Could not create directory: ", err)
-				return err
-			}
-			source, err := os.Open(path)
-			if err != nil {
-				fmt.Println("Could not open source file: ", err)
-				return err
-			}
-			defer source.Close()
-			destination, err := os.Create(newFilePath)
-			if err != nil {
-				fmt.Println("Could not create destination file: ", err)
-				return err
-			}
-			defer destination.Close()
-			_, err = io.Copy(destination, source)
-			if err != nil {
-				fmt.Println("Could not copy file: ", err)
-				return err
-			}
+			copyPaths = append(copyPaths, path)
 		}
 		return nil
 	})
-	return err
+	if err != nil {
+		return err
+	}
+	// Report files that can't be copied and carry on with the rest
+	err = forEach(len(copyPaths), func(i int) error {
+		return app.recordError(copyPaths[i], app.copyFile(copyPaths[i]))
+	})
+	if err != nil {
+		return err
+	}
+	// Read the pages concurrently, then add them in the order of the walk
+	pages := make([]Page, len(pagePaths))
+	errs := make([]error, len(pagePaths))
+	forEach(len(pagePaths), func(i int) error {
+		pages[i], errs[i] = app.getPage(pagePaths[i])
+		return nil
+	})
+	for i, path := range pagePaths {
+		page, err := pages[i], errs[i]
+		// Skip pages we can't read because they could be README, LICENSE, drafts, etc.
+		if invalid, ok := err.(InvalidPageError); ok {
+			app.skipPage(path, invalid)
+			continue
+		} else if err != nil {
+			// Report broken pages and carry on with the rest
+			if err := app.recordError(path, err); err != nil {
+				return err
+			}
+			continue
+		}
+		app.Pages = append(app.Pages, page)
+	}
+	return nil
 }
 
 func InitApp(srcDir string) (App, error) {
@@ -263,7 +434,11 @@ func InitApp(srcDir string) (App, error) {
 	if err != nil {
 		return app, err
 	}
+	app.Config = squatchConfig
 	app.DistDir = squatchConfig.DistDir
+	app.ThemeConfig = squatchConfig.ThemeConfig
+	app.BuildTime = time.Now()
+	app.Report = &BuildReport{}
 	// load the list of folders to ignore
 	app.IgnoreFolders = map[string]bool{app.DistDir: true}
 	for _, folder := range squatchConfig.IgnoreFolders {
@@ -288,6 +463,7 @@ func InitApp(srcDir string) (App, error) {
 	if err != nil {
 		return app, err
 	}
+	app.indexPages()
 	return app, nil
 }
 
@@ -301,7 +477,10 @@ func (app App) printDistFolder() {
 	})
 }
 
-func Build(srcDir string) {
+// build renders srcDir into its dist directory. Problems with single files
+// are collected and reported together, failing the build if there are errors,
+// or warnings in a strict build.
+func build(srcDir string, strict bool) error {
 	fmt.Println("Starting build...")
 	// Get input variables from Github Actions
 	srcDirEnv := os.Getenv("INPUT_SRCDIR")
@@ -313,15 +492,29 @@ func Build(srcDir string) {
 
 	// Initialize the app
 	app, err := InitApp(srcDir)
-	check(err)
+	if err != nil {
+		return err
+	}
+	app.Report.Strict = strict
 
 	// Convert all pages
-	for _, page := range app.Pages {
-		err = app.renderPage(page)
-		check(err)
+	err = app.renderSite()
+	if err != nil {
+		return err
+	}
+	if err := app.Report.Err(); err != nil {
+		return err
 	}
+	app.Report.print(os.Stdout)
 	fmt.Println("Build complete! Dist folder:")
 	app.printDistFolder()
+	return nil
+}
+
+// Build renders srcDir into its dist directory and returns the problems that
+// failed the build
+func Build(srcDir string) error {
+	return build(srcDir, false)
 }
 
 func main() {
@@ -330,10 +523,20 @@ func main() {
 	var port string
 	flag.StringVar(&port, "port", "8080", "Port to run the live server on")
 	liveServerPtr := flag.Bool("live-server", false, "Run a live server")
+	highlightCSS := flag.String("highlight-css", "", "Print the css of a syntax highlighting style and exit")
+	strict := flag.Bool("strict", false, "Fail the build on warnings like missing layouts")
+	flag.IntVar(&jobs, "j", jobs, "Number of files to build at the same time")
 	flag.Parse()
-	if *liveServerPtr {
-		LiveServer(srcDir, port)
+	var err error
+	if *highlightCSS != "" {
+		err = writeHighlightCSS(os.Stdout, *highlightCSS)
+	} else if *liveServerPtr {
+		LiveServer(srcDir, port, *strict)
 	} else {
-		Build(srcDir)
+		err = build(srcDir, *strict)
+	}
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
 	}
 }
@@ -1,9 +1,9 @@
 package main
 
 import (
-	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 )
 
@@ -11,16 +11,6 @@ func cleanup(dist string) {
 	os.RemoveAll(dist)
 }
 
-func TestCheckError(t *testing.T) {
-	defer func() {
-		if r := recover(); r == nil {
-			t.Errorf("expected check to panic, got %v", r)
-		}
-	}()
-
-	check(fmt.Errorf("test error"))
-}
-
 func TestInitApp(t *testing.T) {
 	srcTest := "src_test"
 	app, err := InitApp(srcTest)
@@ -173,3 +163,134 @@ func TestInitAppIgnoreLists(t *testing.T) {
                 t.Errorf("expected IgnoreFiles to contain 'README.md'")
         }
 }
+
+func TestInitAppThemeConfig(t *testing.T) {
+	srcTest := "src_test"
+	app, err := InitApp(srcTest)
+	defer cleanup(app.DistDir)
+	if err != nil {
+		t.Fatalf("expected InitApp to return no error, got %v", err)
+	}
+	if app.ThemeConfig.Heading.Level.One != "title is-1 has-text-centered" {
+		t.Errorf("expected heading level 1 class to be 'title is-1 has-text-centered', got %v", app.ThemeConfig.Heading.Level.One)
+	}
+	page, err := app.getPage(filepath.Join(srcTest, "index.md"))
+	if err != nil {
+		t.Fatalf("expected getPage to return no error, got %v", err)
+	}
+	if !strings.Contains(string(page.Body), `<h1 id="this-is-the-main-page" class="title is-1 has-text-centered">`) {
+		t.Errorf("expected heading to have theme class, got %v", page.Body)
+	}
+}
+
+func TestGetPageParams(t *testing.T) {
+	srcTest := "src_test"
+	app, _ := InitApp(srcTest)
+	defer cleanup(app.DistDir)
+	page, err := app.getPage(filepath.Join(srcTest, "pages", "params.md"))
+	if err != nil {
+		t.Fatalf("expected getPage to return no error, got %v", err)
+	}
+	if page.Title != "Params Title" {
+		t.Errorf("expected page title to be 'Params Title', got %v", page.Title)
+	}
+	if page.Params["author"] != "Jane Doe" {
+		t.Errorf("expected author to be 'Jane Doe', got %v", page.Params["author"])
+	}
+	if strings.Contains(string(page.Body), "author") {
+		t.Errorf("expected front matter to be removed from the body, got %v", page.Body)
+	}
+}
+
+func TestGetPageTOML(t *testing.T) {
+	srcTest := "src_test"
+	app, _ := InitApp(srcTest)
+	defer cleanup(app.DistDir)
+	page, err := app.getPage(filepath.Join(srcTest, "pages", "toml.md"))
+	if err != nil {
+		t.Fatalf("expected getPage to return no error, got %v", err)
+	}
+	if page.Title != "TOML Title" {
+		t.Errorf("expected page title to be 'TOML Title', got %v", page.Title)
+	}
+	if page.Layout != "pages" {
+		t.Errorf("expected page layout to be 'pages', got %v", page.Layout)
+	}
+}
+
+func TestRenderPageParams(t *testing.T) {
+	srcTest := "src_test"
+	defer cleanup("dist")
+	Build(srcTest)
+	data, err := os.ReadFile(filepath.Join("dist", "pages", "params.html"))
+	if err != nil {
+		t.Fatalf("expected params.html to exist, got %v", err)
+	}
+	if !strings.Contains(string(data), `<p class="author">Jane Doe</p>`) {
+		t.Errorf("expected author to be rendered, got %s", string(data))
+	}
+	if !strings.Contains(string(data), "<li>go</li><li>markdown</li>") {
+		t.Errorf("expected tags to be rendered, got %s", string(data))
+	}
+}
+
+func TestGetPageMetadataParams(t *testing.T) {
+	srcTest := "src_test"
+	app, _ := InitApp(srcTest)
+	defer cleanup(app.DistDir)
+	page, err := app.getPage(filepath.Join(srcTest, "pages", "metadata.md"))
+	if err != nil {
+		t.Fatalf("expected getPage to return no error, got %v", err)
+	}
+	if page.Title != "Metadata Title" {
+		t.Errorf("expected page title to be 'Metadata Title', got %v", page.Title)
+	}
+	if page.Params["author"] != "John Doe" {
+		t.Errorf("expected author to be 'John Doe', got %v", page.Params["author"])
+	}
+	if page.Params["draft"] != false {
+		t.Errorf("expected draft to be false, got %v", page.Params["draft"])
+	}
+	if tags, ok := page.Params["tags"].([]interface{}); !ok || len(tags) != 2 {
+		t.Errorf("expected two tags, got %v", page.Params["tags"])
+	}
+}
+
+func TestPageURL(t *testing.T) {
+	app := App{SrcDir: "src"}
+	tests := map[string]string{
+		filepath.Join("src", "index.md"):               "/",
+		filepath.Join("src", "about.md"):               "/about.html",
+		filepath.Join("src", "pages", "example.md"):    "/pages/example.html",
+		filepath.Join("src", "pages", "index.md"):      "/pages/",
+		filepath.Join("src", "pages", "post.index.md"): "/pages/post.index.html",
+	}
+	for fp, expected := range tests {
+		if url := app.pageURL(fp); url != expected {
+			t.Errorf("expected url of %v to be %v, got %v", fp, expected, url)
+		}
+	}
+}
+
+func TestRenderPageSite(t *testing.T) {
+	srcTest := "src_test"
+	defer cleanup("dist")
+	Build(srcTest)
+	data, err := os.ReadFile(filepath.Join("dist", "pages", "example.html"))
+	if err != nil {
+		t.Fatalf("expected example.html to exist, got %v", err)
+	}
+	page := string(data)
+	if !strings.Contains(page, "<title>Example page title | Squatch Test</title>") {
+		t.Errorf("expected site title to be rendered, got %s", page)
+	}
+	if !strings.Contains(page, `content="Site for unit tests"`) {
+		t.Errorf("expected site params to be rendered, got %s", page)
+	}
+	if !strings.Contains(page, `<a href="/">Squatch</a>`) {
+		t.Errorf("expected navigation to link the index page, got %s", page)
+	}
+	if !strings.Contains(page, `<a href="/pages/frontmatter.html">Frontmatter Title</a>`) {
+		t.Errorf("expected navigation to link other pages, got %s", page)
+	}
+}
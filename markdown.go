@@ -0,0 +1,87 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/gomarkdown/markdown/html"
+	"github.com/gomarkdown/markdown/parser"
+)
+
+// commonOption selects the default extensions or flags of the markdown parser
+const commonOption = "common"
+
+// markdownExtensions maps the extension names in .squatch to parser extensions
+var markdownExtensions = map[string]parser.Extensions{
+	commonOption:             parser.CommonExtensions,
+	"noIntraEmphasis":        parser.NoIntraEmphasis,
+	"tables":                 parser.Tables,
+	"fencedCode":             parser.FencedCode,
+	"autolink":               parser.Autolink,
+	"strikethrough":          parser.Strikethrough,
+	"laxHTMLBlocks":          parser.LaxHTMLBlocks,
+	"spaceHeadings":          parser.SpaceHeadings,
+	"hardLineBreak":          parser.HardLineBreak,
+	"nonBlockingSpace":       parser.NonBlockingSpace,
+	"tabSizeEight":           parser.TabSizeEight,
+	"footnotes":              parser.Footnotes,
+	"noEmptyLineBeforeBlock": parser.NoEmptyLineBeforeBlock,
+	"headingIDs":             parser.HeadingIDs,
+	"titleblock":             parser.Titleblock,
+	"backslashLineBreak":     parser.BackslashLineBreak,
+	"definitionLists":        parser.DefinitionLists,
+	"mathJax":                parser.MathJax,
+	"orderedListStart":       parser.OrderedListStart,
+	"attributes":             parser.Attributes,
+	"superSubscript":         parser.SuperSubscript,
+	"emptyLinesBreakList":    parser.EmptyLinesBreakList,
+	"mmark":                  parser.Mmark,
+}
+
+// markdownFlags maps the flag names in .squatch to html renderer flags
+var markdownFlags = map[string]html.Flags{
+	commonOption:              html.CommonFlags,
+	"skipHTML":                html.SkipHTML,
+	"skipImages":              html.SkipImages,
+	"skipLinks":               html.SkipLinks,
+	"safelink":                html.Safelink,
+	"nofollowLinks":           html.NofollowLinks,
+	"noreferrerLinks":         html.NoreferrerLinks,
+	"noopenerLinks":           html.NoopenerLinks,
+	"hrefTargetBlank":         html.HrefTargetBlank,
+	"useXHTML":                html.UseXHTML,
+	"footnoteReturnLinks":     html.FootnoteReturnLinks,
+	"footnoteNoHRTag":         html.FootnoteNoHRTag,
+	"smartypants":             html.Smartypants,
+	"smartypantsFractions":    html.SmartypantsFractions,
+	"smartypantsDashes":       html.SmartypantsDashes,
+	"smartypantsLatexDashes":  html.SmartypantsLatexDashes,
+	"smartypantsAngledQuotes": html.SmartypantsAngledQuotes,
+	"smartypantsQuotesNBSP":   html.SmartypantsQuotesNBSP,
+	"lazyLoadImages":          html.LazyLoadImages,
+}
+
+// options returns the parser extensions and renderer flags named in the
+// config. Without any extensions listed the parser's common extensions are
+// used.
+func (config MarkdownConfig) options() (parser.Extensions, html.Flags, error) {
+	extensions := parser.CommonExtensions
+	if config.Extensions != nil {
+		extensions = parser.NoExtensions
+	}
+	for _, name := range config.Extensions {
+		extension, ok := markdownExtensions[name]
+		if !ok {
+			return extensions, html.FlagsNone, fmt.Errorf("unknown markdown extension %q", name)
+		}
+		extensions |= extension
+	}
+	flags := html.FlagsNone
+	for _, name := range config.Flags {
+		flag, ok := markdownFlags[name]
+		if !ok {
+			return extensions, flags, fmt.Errorf("unknown markdown flag %q", name)
+		}
+		flags |= flag
+	}
+	return extensions, flags, nil
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestMarkdownConfig(t *testing.T) {
+	tests := []struct {
+		name     string
+		config   MarkdownConfig
+		md       string
+		expected string
+	}{
+		{"default autolink", MarkdownConfig{}, "see https://example.com", `<a href="https://example.com">`},
+		{"extensions replace defaults", MarkdownConfig{Extensions: []string{"tables"}}, "see https://example.com", "<p>see https://example.com</p>"},
+		{"common and more", MarkdownConfig{Extensions: []string{"common", "footnotes"}}, "Text[^1]\n\n[^1]: Note", `<div class="footnotes">`},
+		{"hard line breaks", MarkdownConfig{Extensions: []string{"hardLineBreak"}}, "one\ntwo", "one<br>\ntwo"},
+		{"target blank", MarkdownConfig{Flags: []string{"hrefTargetBlank"}}, "[out](https://example.com) [in](/docs/)",
+			`<a href="https://example.com" target="_blank">out</a> <a href="/docs/">in</a>`},
+		{"smartypants", MarkdownConfig{Flags: []string{"smartypants", "smartypantsDashes"}}, `"quoted" -- dash`, "&ldquo;quoted&rdquo; &mdash; dash"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := App{Config: SquatchConfig{Markdown: tt.config}}
+			output := string(app.markdownToHTML([]byte(tt.md)))
+			if !strings.Contains(output, tt.expected) {
+				t.Errorf("Expected output to contain %s, got: %s", tt.expected, output)
+			}
+		})
+	}
+}
+
+func TestMarkdownConfigUnknown(t *testing.T) {
+	if _, _, err := (MarkdownConfig{Extensions: []string{"nope"}}).options(); err == nil {
+		t.Errorf("expected an error for an unknown extension")
+	}
+	if _, _, err := (MarkdownConfig{Flags: []string{"nope"}}).options(); err == nil {
+		t.Errorf("expected an error for an unknown flag")
+	}
+	fp := filepath.Join(t.TempDir(), ".squatch")
+	os.WriteFile(fp, []byte(`{"markdown": {"flags": ["nope"]}}`), 0644)
+	if _, err := getSquatchConfig(fp); err == nil {
+		t.Errorf("expected getSquatchConfig to reject unknown flags")
+	}
+}
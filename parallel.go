@@ -0,0 +1,46 @@
+package main
+
+import (
+	"runtime"
+	"sync"
+)
+
+// jobs is the number of files read, rendered or copied at the same time. It
+// is set with the -j flag.
+var jobs = runtime.NumCPU()
+
+// forEach calls work with every index below n on up to jobs goroutines. It
+// returns the error of the lowest index that failed so the result doesn't
+// depend on the order the work finished in.
+func forEach(n int, work func(i int) error) error {
+	workers := jobs
+	if workers > n {
+		workers = n
+	}
+	if workers < 1 {
+		workers = 1
+	}
+	errs := make([]error, n)
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				errs[i] = work(i)
+			}
+		}()
+	}
+	for i := 0; i < n; i++ {
+		indexes <- i
+	}
+	close(indexes)
+	wg.Wait()
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
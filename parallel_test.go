@@ -0,0 +1,80 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+)
+
+func TestForEach(t *testing.T) {
+	defer func(n int) { jobs = n }(jobs)
+	for _, n := range []int{0, 1, 4} {
+		jobs = n
+		var calls int32
+		seen := make([]bool, 10)
+		err := forEach(len(seen), func(i int) error {
+			atomic.AddInt32(&calls, 1)
+			seen[i] = true
+			return nil
+		})
+		if err != nil || calls != 10 {
+			t.Errorf("jobs %d: expected 10 calls and no error, got %d and %v", n, calls, err)
+		}
+		for i, ok := range seen {
+			if !ok {
+				t.Errorf("jobs %d: expected index %d to be worked on", n, i)
+			}
+		}
+	}
+}
+
+func TestForEachError(t *testing.T) {
+	defer func(n int) { jobs = n }(jobs)
+	jobs = 4
+	err := forEach(10, func(i int) error {
+		if i == 3 || i == 7 {
+			return fmt.Errorf("failed %d", i)
+		}
+		return nil
+	})
+	if err == nil || err.Error() != "failed 3" {
+		t.Errorf("expected the error of the lowest index, got %v", err)
+	}
+}
+
+// TestBuildDeterministic checks that concurrent builds write the same files
+// as a build with a single job
+func TestBuildDeterministic(t *testing.T) {
+	defer func(n int) { jobs = n }(jobs)
+	outputs := []map[string]string{}
+	for _, n := range []int{1, 8} {
+		jobs = n
+		app, err := InitApp("src_test")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := app.renderSite(); err != nil {
+			t.Fatal(err)
+		}
+		files := map[string]string{}
+		filepath.Walk(app.DistDir, func(path string, info os.FileInfo, err error) error {
+			if err == nil && !info.IsDir() {
+				data, _ := os.ReadFile(path)
+				files[path] = string(data)
+			}
+			return err
+		})
+		outputs = append(outputs, files)
+		cleanup(app.DistDir)
+	}
+	if len(outputs[0]) != len(outputs[1]) {
+		t.Fatalf("expected the same files, got %d and %d", len(outputs[0]), len(outputs[1]))
+	}
+	for path, data := range outputs[0] {
+		if outputs[1][path] != data {
+			t.Errorf("expected %v to be the same with 1 and 8 jobs", path)
+		}
+	}
+}
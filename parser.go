@@ -1,23 +1,119 @@
 package main
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
 	"io"
 	"os"
 
+	"github.com/gomarkdown/markdown"
 	"github.com/gomarkdown/markdown/ast"
+	"github.com/gomarkdown/markdown/html"
+	"github.com/gomarkdown/markdown/parser"
 )
 
 type SquatchConfig struct {
-	DistDir       string      `json:"dist"`
-	IgnoreFolders []string    `json:"ignoreFolders"`
-	IgnoreFiles   []string    `json:"ignoreFiles"`
-	ThemeConfig   ThemeConfig `json:"theme"`
+	DistDir       string                   `json:"dist"`
+	IgnoreFolders []string                 `json:"ignoreFolders"`
+	IgnoreFiles   []string                 `json:"ignoreFiles"`
+	ThemeConfig   ThemeConfig              `json:"theme"`
+	Title         string                   `json:"title"`
+	Author        string                   `json:"author"`
+	Params        map[string]interface{}   `json:"params"`
+	Sections      map[string]SectionConfig `json:"sections"`
+	BaseURL       string                   `json:"baseURL"`
+	Feed          *FeedConfig              `json:"feed"`
+	Robots        RobotsConfig             `json:"robots"`
+	Search        *SearchConfig            `json:"search"`
+	TOC           TOCConfig                `json:"toc"`
+	Anchors       AnchorConfig             `json:"anchors"`
+	Markdown      MarkdownConfig           `json:"markdown"`
+	Highlight     *HighlightConfig         `json:"highlight"`
+	// Templates is "html" to render layouts with html/template, or "text"
+	Templates string `json:"templates"`
+	// MissingLayout is what happens to pages whose layout can't be found:
+	// "skip", "default" or "fail"
+	MissingLayout string `json:"missingLayout"`
+}
+
+// HighlightConfig configures the syntax highlighting of code blocks
+type HighlightConfig struct {
+	// Style is the name of the chroma style, defaults to github
+	Style string `json:"style"`
+	// Classes uses css classes instead of inline styles
+	Classes bool `json:"classes"`
+	// LineNumbers numbers the lines of each code block
+	LineNumbers bool `json:"lineNumbers"`
+}
+
+// MarkdownConfig picks the markdown dialect of the site
+type MarkdownConfig struct {
+	// Extensions replaces the default parser extensions
+	Extensions []string `json:"extensions"`
+	// Flags are the html renderer options
+	Flags []string `json:"flags"`
+}
+
+// AnchorConfig configures the permalinks added to headings
+type AnchorConfig struct {
+	// Permalink adds a link to each heading's id at the end of the heading
+	Permalink bool `json:"permalink"`
+	// Symbol is the text of the link, defaults to #
+	Symbol string `json:"symbol"`
+	// Class of the link, defaults to anchor
+	Class string `json:"class"`
+}
+
+// TOCConfig configures the table of contents of each page
+type TOCConfig struct {
+	// MinLevel is the highest heading level in the table of contents
+	MinLevel int `json:"minLevel"`
+	// MaxLevel is the lowest heading level in the table of contents
+	MaxLevel int `json:"maxLevel"`
+}
+
+// SearchConfig configures the client side search index
+type SearchConfig struct {
+	// ShardSize splits the index into files of this many pages
+	ShardSize int `json:"shardSize"`
+}
+
+// RobotsConfig configures the generated robots.txt
+type RobotsConfig struct {
+	// Disallow lists the paths crawlers should skip
+	Disallow []string `json:"disallow"`
+	// Content replaces the generated robots.txt entirely
+	Content string `json:"content"`
+}
+
+// FeedConfig configures the RSS and Atom feeds of dated pages
+type FeedConfig struct {
+	// Title of the feed, defaults to the site title
+	Title       string `json:"title"`
+	Description string `json:"description"`
+	// Section limits the feed to the pages of one section
+	Section string `json:"section"`
+	// Limit is the number of newest pages in the feed
+	Limit int `json:"limit"`
+	// FullContent includes the whole page instead of a summary
+	FullContent bool `json:"fullContent"`
+}
+
+// SectionConfig configures the pages in a folder of the source directory
+type SectionConfig struct {
+	// Type is "posts" for dated posts with paginated listings
+	Type string `json:"type"`
+	// Title of the list pages, defaults to the folder name
+	Title string `json:"title"`
+	// Layout used to render the list pages
+	Layout string `json:"layout"`
+	// PerPage is the number of posts on each list page
+	PerPage int `json:"perPage"`
 }
 
 type ThemeConfig struct {
-	BlockTemplate    string         `json:"block_template"`
+	BlockQuote       string         `json:"block_quote"`
 	List             List           `json:"list"`
 	ListItem         ListItem       `json:"list_item"`
 	Paragraph        string         `json:"paragraph"`
@@ -35,7 +131,6 @@ type ThemeConfig struct {
 	Text             string         `json:"text"`
 	HTMLBlock        string         `json:"html_block"`
 	CodeBlock        CodeBlock      `json:"code_block"`
-	Softbreak        string         `json:"softbreak"`
 	Hardbreak        string         `json:"hardbreak"`
 	NonBlockingSpace string         `json:"non_blocking_space"`
 	Code             string         `json:"code"`
@@ -56,9 +151,11 @@ type ThemeConfig struct {
 }
 
 type List struct {
+	Class string `json:"class"`
 }
 
 type ListItem struct {
+	Class string `json:"class"`
 }
 
 type Heading struct {
@@ -74,25 +171,50 @@ type Level struct {
 	Six   string `json:"6"`
 }
 
+// class returns the configured class for a heading of the given level
+func (l Level) class(level int) string {
+	switch level {
+	case 1:
+		return l.One
+	case 2:
+		return l.Two
+	case 3:
+		return l.Three
+	case 4:
+		return l.Four
+	case 5:
+		return l.Five
+	default:
+		return l.Six
+	}
+}
+
 type Link struct {
+	Class string `json:"class"`
 }
 
 type CrossReference struct {
+	Class string `json:"class"`
 }
 
 type Citation struct {
+	Class string `json:"class"`
 }
 
 type CodeBlock struct {
+	Class string `json:"class"`
 }
 
 type TableCell struct {
+	Class string `json:"class"`
 }
 
 type Callout struct {
+	Class string `json:"class"`
 }
 
 type Index struct {
+	Class string `json:"class"`
 }
 
 func getSquatchConfig(fp string) (SquatchConfig, error) {
@@ -108,66 +230,382 @@ func getSquatchConfig(fp string) (SquatchConfig, error) {
 		fmt.Println("Could not parse config file: ", fp)
 		return configStruct, err
 	}
+	if _, _, err := configStruct.Markdown.options(); err != nil {
+		fmt.Printf("Invalid markdown config in %v: %v\n", fp, err)
+		return configStruct, err
+	}
+	if err := checkTemplateMode(configStruct.Templates); err != nil {
+		fmt.Printf("Invalid templates config in %v: %v\n", fp, err)
+		return configStruct, err
+	}
+	if err := checkMissingLayout(configStruct.MissingLayout); err != nil {
+		fmt.Printf("Invalid missingLayout config in %v: %v\n", fp, err)
+		return configStruct, err
+	}
+	if configStruct.Highlight != nil {
+		if _, err := configStruct.Highlight.style(); err != nil {
+			fmt.Printf("Invalid highlight config in %v: %v\n", fp, err)
+			return configStruct, err
+		}
+	}
 	return configStruct, nil
 }
 
-func (app App) renderHook(w io.Writer, node ast.Node, entering bool) (ast.WalkStatus, bool) {
-	// TODO: implement this
-	if _, ok := node.(*ast.List); ok {
-		return ast.GoToNext, false
-	} else if _, ok := node.(*ast.ListItem); ok {
-		return ast.GoToNext, false
-	} else if _, ok := node.(*ast.Paragraph); ok {
-		return ast.GoToNext, false
-	} else if _, ok := node.(*ast.Math); ok {
-		return ast.GoToNext, false
-	} else if _, ok := node.(*ast.MathBlock); ok {
-		return ast.GoToNext, false
-	} else if _, ok := node.(*ast.Heading); ok {
-		return ast.GoToNext, false
-	} else if _, ok := node.(*ast.HorizontalRule); ok {
-		return ast.GoToNext, false
-	} else if _, ok := node.(*ast.Emph); ok {
-		return ast.GoToNext, false
-	} else if _, ok := node.(*ast.Strong); ok {
-		return ast.GoToNext, false
-	} else if _, ok := node.(*ast.Del); ok {
-		return ast.GoToNext, false
-	} else if _, ok := node.(*ast.Link); ok {
-		return ast.GoToNext, false
-	} else if _, ok := node.(*ast.CrossReference); ok {
-		return ast.GoToNext, false
-	} else if _, ok := node.(*ast.Citation); ok {
-		return ast.GoToNext, false
-	} else if _, ok := node.(*ast.Image); ok {
-		return ast.GoToNext, false
-	} else if _, ok := node.(*ast.Text); ok {
-		return ast.GoToNext, false
-	} else if _, ok := node.(*ast.HTMLBlock); ok {
-		return ast.GoToNext, false
-	} else if _, ok := node.(*ast.CodeBlock); ok {
-		return ast.GoToNext, false
-	} else if _, ok := node.(*ast.Softbreak); ok {
-		return ast.GoToNext, false
-	} else if _, ok := node.(*ast.Hardbreak); ok {
-		return ast.GoToNext, false
-	} else if _, ok := node.(*ast.NonBlockingSpace); ok {
+// markdownToHTML renders markdown with the theme config applied
+func (app App) markdownToHTML(md []byte) []byte {
+	body, _ := app.renderMarkdown(md)
+	return body
+}
+
+// renderMarkdown renders markdown with the theme config applied and returns
+// it with the table of contents of its headings
+func (app App) renderMarkdown(md []byte) ([]byte, TOC) {
+	// The config is checked when it is loaded
+	extensions, flags, _ := app.Config.Markdown.options()
+	renderer := app.newRenderer(flags)
+	if app.Config.Highlight != nil {
+		md = highlightFences(md)
+	}
+	doc := markdown.Parse(md, parser.NewWithExtensions(extensions))
+	toc := app.buildTOC(headingIDs(doc))
+	return markdown.Render(doc, renderer), toc
+}
+
+// newRenderer returns an html renderer for flags with the theme config applied
+func (app App) newRenderer(flags html.Flags) *html.Renderer {
+	var renderer *html.Renderer
+	renderer = html.NewRenderer(html.RendererOptions{
+		Flags: flags,
+		RenderNodeHook: func(w io.Writer, node ast.Node, entering bool) (ast.WalkStatus, bool) {
+			return app.renderHook(renderer, flags, w, node, entering)
+		},
+	})
+	return renderer
+}
+
+// renderHook adds the classes from the theme config to the rendered nodes.
+// Nodes that the default renderer already writes block attributes for get the
+// class added to their attributes, everything else is written out here.
+// Nodes without a configured class fall through to the default renderer, and
+// so do nodes that flags leave out of the output.
+func (app App) renderHook(renderer *html.Renderer, flags html.Flags, w io.Writer, node ast.Node, entering bool) (ast.WalkStatus, bool) {
+	theme := app.ThemeConfig
+	switch node := node.(type) {
+	case *ast.BlockQuote:
+		addClass(node, entering, theme.BlockQuote)
+	case *ast.List:
+		if node.IsFootnotesList {
+			addClass(node, entering, theme.Footnotes)
+		} else {
+			addClass(node, entering, theme.List.Class)
+		}
+	case *ast.ListItem:
+		return renderListItem(w, node, entering, theme.ListItem.Class)
+	case *ast.Paragraph:
+		addClass(node, entering, theme.Paragraph)
+	case *ast.Heading:
+		addClass(node, entering, theme.Heading.Level.class(node.Level))
+		if !entering && app.Config.Anchors.Permalink {
+			return renderPermalink(w, node, app.Config.Anchors)
+		}
+	case *ast.HorizontalRule:
+		addClass(node, entering, theme.HorizontalRule)
+	case *ast.Table:
+		addClass(node, entering, theme.Table)
+	case *ast.Link:
+		if entering && theme.Link.Class != "" && node.NoteID == 0 {
+			node.AdditionalAttributes = append(node.AdditionalAttributes, classAttr(theme.Link.Class))
+		}
+	case *ast.Math:
+		return renderLiteral(w, `<span `+classAttr("math inline "+theme.Math)+`>\(`, node.Literal, `\)</span>`, theme.Math)
+	case *ast.MathBlock:
+		if !entering {
+			return ast.GoToNext, theme.MathBlock != ""
+		}
+		return renderLiteral(w, `<p><span `+classAttr("math display "+theme.MathBlock)+`>\[`, node.Literal, `\]</span></p>`, theme.MathBlock)
+	case *ast.Emph:
+		return renderTag(w, "em", entering, theme.Emph)
+	case *ast.Strong:
+		return renderTag(w, "strong", entering, theme.Strong)
+	case *ast.Del:
+		return renderTag(w, "del", entering, theme.Del)
+	case *ast.Caption:
+		return renderTag(w, "figcaption", entering, theme.Caption)
+	case *ast.TableHeader:
+		return renderBlockTag(w, "thead", entering, theme.TableHeader)
+	case *ast.TableBody:
+		return renderBlockTag(w, "tbody", entering, theme.TableBody)
+	case *ast.TableRow:
+		return renderBlockTag(w, "tr", entering, theme.TableRow)
+	case *ast.TableFooter:
+		return renderBlockTag(w, "tfoot", entering, theme.TableFooter)
+	case *ast.TableCell:
+		return renderTableCell(w, node, entering, theme.TableCell.Class)
+	case *ast.Code:
+		return renderLiteral(w, "<code "+classAttr(theme.Code)+">", node.Literal, "</code>", theme.Code)
+	case *ast.Subscript:
+		return renderLiteral(w, "<sub "+classAttr(theme.Subscript)+">", node.Literal, "</sub>", theme.Subscript)
+	case *ast.Superscript:
+		return renderLiteral(w, "<sup "+classAttr(theme.Superscript)+">", node.Literal, "</sup>", theme.Superscript)
+	case *ast.Text:
+		// Text inside an image is its alt attribute, which can't hold a span
+		if len(node.Literal) == 0 || theme.Text == "" || renderer.DisableTags > 0 {
+			return ast.GoToNext, false
+		}
+		io.WriteString(w, "<span "+classAttr(theme.Text)+">")
+		renderer.Text(w, node)
+		io.WriteString(w, "</span>")
+		return ast.GoToNext, true
+	case *ast.NonBlockingSpace:
+		return renderRaw(w, "<span "+classAttr(theme.NonBlockingSpace)+">", []byte("&nbsp;"), "</span>", theme.NonBlockingSpace)
+	case *ast.HTMLSpan:
+		if flags&html.SkipHTML != 0 {
+			return ast.GoToNext, false
+		}
+		return renderRaw(w, "<span "+classAttr(theme.HTMLSpan)+">", node.Literal, "</span>", theme.HTMLSpan)
+	case *ast.HTMLBlock:
+		if flags&html.SkipHTML != 0 {
+			return ast.GoToNext, false
+		}
+		return renderRaw(w, "\n<div "+classAttr(theme.HTMLBlock)+">\n", node.Literal, "\n</div>\n", theme.HTMLBlock)
+	case *ast.Hardbreak:
+		return renderRaw(w, "<br "+classAttr(theme.Hardbreak)+">", nil, "\n", theme.Hardbreak)
+	case *ast.CodeBlock:
+		if app.Config.Highlight != nil {
+			return renderHighlightedCode(w, node, *app.Config.Highlight, theme.CodeBlock.Class)
+		}
+		return renderCodeBlock(w, node, theme.CodeBlock.Class)
+	case *ast.Image:
+		if flags&html.SkipImages != 0 {
+			return ast.GoToNext, false
+		}
+		return renderImage(w, node, entering, flags&html.LazyLoadImages != 0, theme.Image)
+	case *ast.CrossReference:
+		if theme.CrossReference.Class == "" {
+			return ast.GoToNext, false
+		}
+		if entering {
+			io.WriteString(w, `<a href="#`+string(node.Destination)+`" `+classAttr(theme.CrossReference.Class)+">")
+		} else {
+			io.WriteString(w, "</a>")
+		}
+		return ast.GoToNext, true
+	case *ast.Citation:
+		return renderCitation(w, node, theme.Citation.Class)
+	case *ast.CaptionFigure:
+		if theme.CaptionFigure == "" {
+			return ast.GoToNext, false
+		}
+		if entering {
+			attrs := []string{classAttr(theme.CaptionFigure)}
+			if node.HeadingID != "" {
+				attrs = append(attrs, `id="`+node.HeadingID+`"`)
+			}
+			io.WriteString(w, html.TagWithAttributes("<figure", attrs))
+		} else {
+			io.WriteString(w, "\n</figure>\n")
+		}
+		return ast.GoToNext, true
+	case *ast.Callout:
+		return renderRaw(w, `<span `+classAttr("callout "+theme.Callout.Class)+`>`, node.ID, "</span>", theme.Callout.Class)
+	case *ast.Index:
+		return renderRaw(w, `<span `+classAttr("index "+theme.Index.Class)+` id="`+node.ID+`">`, nil, "</span>", theme.Index.Class)
+	}
+	return ast.GoToNext, false
+}
+
+// classAttr returns the html class attribute for class
+func classAttr(class string) string {
+	var escaped bytes.Buffer
+	html.EscapeHTML(&escaped, []byte(class))
+	return `class="` + escaped.String() + `"`
+}
+
+// addClass appends class to the block attributes of node so the default
+// renderer includes it in the opening tag
+func addClass(node ast.Node, entering bool, class string) {
+	if !entering || class == "" {
+		return
+	}
+	if c := node.AsContainer(); c != nil {
+		if c.Attribute == nil {
+			c.Attribute = &ast.Attribute{}
+		}
+		c.Classes = append(c.Classes, []byte(class))
+	} else if l := node.AsLeaf(); l != nil {
+		if l.Attribute == nil {
+			l.Attribute = &ast.Attribute{}
+		}
+		l.Classes = append(l.Classes, []byte(class))
+	}
+}
+
+// renderTag writes the opening or closing tag of an inline container node
+func renderTag(w io.Writer, tag string, entering bool, class string) (ast.WalkStatus, bool) {
+	if class == "" {
 		return ast.GoToNext, false
-	} else if _, ok := node.(*ast.Code); ok {
+	}
+	if entering {
+		io.WriteString(w, "<"+tag+" "+classAttr(class)+">")
+	} else {
+		io.WriteString(w, "</"+tag+">")
+	}
+	return ast.GoToNext, true
+}
+
+// renderBlockTag is like renderTag but puts block nodes on their own line
+func renderBlockTag(w io.Writer, tag string, entering bool, class string) (ast.WalkStatus, bool) {
+	if class == "" {
 		return ast.GoToNext, false
-	} else if _, ok := node.(*ast.HTMLSpan); ok {
+	}
+	if entering {
+		io.WriteString(w, "\n<"+tag+" "+classAttr(class)+">")
+	} else {
+		io.WriteString(w, "</"+tag+">\n")
+	}
+	return ast.GoToNext, true
+}
+
+// renderLiteral writes a leaf node with its escaped literal between open and close
+func renderLiteral(w io.Writer, open string, literal []byte, close string, class string) (ast.WalkStatus, bool) {
+	if class == "" {
 		return ast.GoToNext, false
-	} else if _, ok := node.(*ast.Table); ok {
+	}
+	io.WriteString(w, open)
+	html.EscapeHTML(w, literal)
+	io.WriteString(w, close)
+	return ast.GoToNext, true
+}
+
+// renderRaw writes a leaf node with its unescaped literal between open and close
+func renderRaw(w io.Writer, open string, literal []byte, close string, class string) (ast.WalkStatus, bool) {
+	if class == "" {
 		return ast.GoToNext, false
-	} else if _, ok := node.(*ast.TableCell); ok {
+	}
+	io.WriteString(w, open)
+	w.Write(literal)
+	io.WriteString(w, close)
+	return ast.GoToNext, true
+}
+
+func renderListItem(w io.Writer, item *ast.ListItem, entering bool, class string) (ast.WalkStatus, bool) {
+	// Footnote items carry their own ids, leave them to the default renderer
+	if class == "" || item.RefLink != nil {
 		return ast.GoToNext, false
-	} else if _, ok := node.(*ast.TableHeader); ok {
+	}
+	tag := "li"
+	if item.ListFlags&ast.ListTypeDefinition != 0 {
+		tag = "dd"
+	}
+	if item.ListFlags&ast.ListTypeTerm != 0 {
+		tag = "dt"
+	}
+	if !entering {
+		io.WriteString(w, "</"+tag+">\n")
+		return ast.GoToNext, true
+	}
+	if list, ok := item.Parent.(*ast.List); ok && ast.GetPrevNode(item) != nil {
+		if !list.Tight && list.ListFlags&ast.ListTypeDefinition == 0 {
+			io.WriteString(w, "\n")
+		}
+	}
+	io.WriteString(w, "<"+tag+" "+classAttr(class)+">")
+	return ast.GoToNext, true
+}
+
+func renderTableCell(w io.Writer, cell *ast.TableCell, entering bool, class string) (ast.WalkStatus, bool) {
+	if class == "" {
 		return ast.GoToNext, false
-	} else if _, ok := node.(*ast.TableBody); ok {
+	}
+	tag := "td"
+	if cell.IsHeader {
+		tag = "th"
+	}
+	if !entering {
+		io.WriteString(w, "</"+tag+">\n")
+		return ast.GoToNext, true
+	}
+	attrs := []string{classAttr(class)}
+	if align := cell.Align.String(); align != "" {
+		attrs = append(attrs, fmt.Sprintf(`align="%s"`, align))
+	}
+	if cell.ColSpan > 0 {
+		attrs = append(attrs, fmt.Sprintf(`colspan="%d"`, cell.ColSpan))
+	}
+	if ast.GetPrevNode(cell) == nil {
+		io.WriteString(w, "\n")
+	}
+	io.WriteString(w, html.TagWithAttributes("<"+tag, attrs))
+	return ast.GoToNext, true
+}
+
+func renderCodeBlock(w io.Writer, block *ast.CodeBlock, class string) (ast.WalkStatus, bool) {
+	if class == "" {
 		return ast.GoToNext, false
-	} else if _, ok := node.(*ast.TableRow); ok {
+	}
+	// The language and theme classes have to share a single class attribute
+	classes := class
+	if len(block.Info) > 0 {
+		lang := block.Info
+		if i := bytes.IndexAny(lang, "\t "); i >= 0 {
+			lang = lang[:i]
+		}
+		classes = "language-" + string(lang) + " " + class
+	}
+	io.WriteString(w, "\n<pre><code "+classAttr(classes)+">")
+	html.EscapeHTML(w, block.Literal)
+	io.WriteString(w, "</code></pre>\n")
+	return ast.GoToNext, true
+}
+
+func renderImage(w io.Writer, image *ast.Image, entering bool, lazy bool, class string) (ast.WalkStatus, bool) {
+	if class == "" {
 		return ast.GoToNext, false
+	}
+	if !entering {
+		return ast.GoToNext, true
+	}
+	// The alt text is the plain text of the image's children
+	var alt bytes.Buffer
+	ast.WalkFunc(image, func(node ast.Node, entering bool) ast.WalkStatus {
+		if leaf := node.AsLeaf(); leaf != nil && entering {
+			alt.Write(leaf.Literal)
+		}
+		return ast.GoToNext
+	})
+	if lazy {
+		io.WriteString(w, `<img loading="lazy" src="`)
 	} else {
+		io.WriteString(w, `<img src="`)
+	}
+	html.EscapeHTML(w, image.Destination)
+	io.WriteString(w, `" alt="`)
+	html.EscapeHTML(w, alt.Bytes())
+	if image.Title != nil {
+		io.WriteString(w, `" title="`)
+		html.EscapeHTML(w, image.Title)
+	}
+	io.WriteString(w, `" `+classAttr(class)+` />`)
+	return ast.SkipChildren, true
+}
+
+func renderCitation(w io.Writer, citation *ast.Citation, class string) (ast.WalkStatus, bool) {
+	if class == "" {
 		return ast.GoToNext, false
 	}
+	for i, dest := range citation.Destination {
+		citeType := "none"
+		switch citation.Type[i] {
+		case ast.CitationTypeNormative:
+			citeType = "normative"
+		case ast.CitationTypeInformative:
+			citeType = "informative"
+		case ast.CitationTypeSuppressed:
+			citeType = "suppressed"
+		}
+		io.WriteString(w, `<cite class="`+citeType+" "+class+`">`)
+		io.WriteString(w, fmt.Sprintf(`<a href="#%s">%s</a>`, dest, dest))
+		io.WriteString(w, "</cite>")
+	}
+	return ast.GoToNext, true
 }
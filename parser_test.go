@@ -21,11 +21,7 @@ func TestParserHeading(t *testing.T) {
 	}
 	md := []byte("# Heading One\n## Heading Two\n### Heading Three\n#### Heading Four\n##### Heading Five\n###### Heading Six")
 	// render the markdown file
-	opts := html.RendererOptions{
-		Flags:          html.FlagsNone,
-		RenderNodeHook: app.renderHook,
-	}
-	renderer := html.NewRenderer(opts)
+	renderer := app.newRenderer(html.FlagsNone)
 	output := string(markdown.ToHTML(md, nil, renderer))
 	output = strings.ReplaceAll(output, "\n", "")
 	output = strings.ReplaceAll(output, "\t", "")
@@ -34,3 +30,94 @@ func TestParserHeading(t *testing.T) {
 		t.Errorf("Expected: %s, got: %s", "<h1>Heading One</h1><h2>Heading Two</h2><h3>Heading Three</h3><h4>Heading Four</h4><h5>Heading Five</h5><h6>Heading Six</h6>", output)
 	}
 }
+
+func TestParserThemeClasses(t *testing.T) {
+	tests := []struct {
+		name     string
+		theme    ThemeConfig
+		md       string
+		expected string
+	}{
+		{"heading", ThemeConfig{Heading: Heading{Level: Level{One: "title is-1", Three: "title is-3"}}}, "# One\n\n## Two\n\n### Three", `<h1 id="one" class="title is-1">One</h1>`},
+		{"heading unconfigured level", ThemeConfig{Heading: Heading{Level: Level{One: "title is-1"}}}, "## Two", `<h2 id="two">Two</h2>`},
+		{"heading six", ThemeConfig{Heading: Heading{Level: Level{Six: "title is-6"}}}, "###### Six", `<h6 id="six" class="title is-6">Six</h6>`},
+		{"paragraph", ThemeConfig{Paragraph: "content"}, "Some text", `<p class="content">Some text</p>`},
+		{"block quote", ThemeConfig{BlockQuote: "quote"}, "> quoted", `<blockquote class="quote">`},
+		{"list", ThemeConfig{List: List{Class: "list"}}, "- one\n- two", `<ul class="list">`},
+		{"list item", ThemeConfig{ListItem: ListItem{Class: "item"}}, "1. one\n2. two", `<li class="item">one</li>`},
+		{"horizontal rule", ThemeConfig{HorizontalRule: "divider"}, "one\n\n***\n\ntwo", `<hr class="divider">`},
+		{"emph", ThemeConfig{Emph: "is-italic"}, "*italic*", `<em class="is-italic">italic</em>`},
+		{"strong", ThemeConfig{Strong: "has-text-weight-bold"}, "**bold**", `<strong class="has-text-weight-bold">bold</strong>`},
+		{"del", ThemeConfig{Del: "strike"}, "~~gone~~", `<del class="strike">gone</del>`},
+		{"link", ThemeConfig{Link: Link{Class: "link"}}, "[GoSquatch](https://example.com)", `<a class="link" href="https://example.com">GoSquatch</a>`},
+		{"image", ThemeConfig{Image: "image"}, "![alt text](/img.png)", `<img src="/img.png" alt="alt text" class="image" />`},
+		{"code", ThemeConfig{Code: "inline"}, "`a < b`", `<code class="inline">a &lt; b</code>`},
+		{"code block", ThemeConfig{CodeBlock: CodeBlock{Class: "code"}}, "```go\nfmt.Println()\n```", `<pre><code class="language-go code">fmt.Println()`},
+		{"code block no language", ThemeConfig{CodeBlock: CodeBlock{Class: "code"}}, "```\nplain\n```", `<pre><code class="code">plain`},
+		{"text", ThemeConfig{Text: "txt"}, "plain", `<span class="txt">plain</span>`},
+		{"html span", ThemeConfig{HTMLSpan: "raw"}, "a <b>b</b>", `<span class="raw"><b></span>`},
+		{"html block", ThemeConfig{HTMLBlock: "raw"}, "<div>\nraw\n</div>\n", "<div class=\"raw\">\n<div>"},
+		{"hardbreak", ThemeConfig{Hardbreak: "br"}, "one\\\ntwo", `<br class="br">`},
+		{"math", ThemeConfig{Math: "m"}, "$x^2$", `<span class="math inline m">\(x^2\)</span>`},
+		{"math block", ThemeConfig{MathBlock: "m"}, "$$\nx^2\n$$", `<span class="math display m">\[`},
+		{"table", ThemeConfig{Table: "table"}, "| a | b |\n|---|---|\n| 1 | 2 |", `<table class="table">`},
+		{"table header", ThemeConfig{TableHeader: "head"}, "| a | b |\n|---|---|\n| 1 | 2 |", `<thead class="head">`},
+		{"table body", ThemeConfig{TableBody: "body"}, "| a | b |\n|---|---|\n| 1 | 2 |", `<tbody class="body">`},
+		{"table row", ThemeConfig{TableRow: "row"}, "| a | b |\n|---|---|\n| 1 | 2 |", `<tr class="row">`},
+		{"table cell", ThemeConfig{TableCell: TableCell{Class: "cell"}}, "| a | b |\n|--:|---|\n| 1 | 2 |", `<td class="cell" align="right">1</td>`},
+		{"table header cell", ThemeConfig{TableCell: TableCell{Class: "cell"}}, "| a | b |\n|---|---|\n| 1 | 2 |", `<th class="cell">a</th>`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := App{ThemeConfig: tt.theme}
+			output := string(app.markdownToHTML([]byte(tt.md)))
+			if !strings.Contains(output, tt.expected) {
+				t.Errorf("Expected output to contain %s, got: %s", tt.expected, output)
+			}
+		})
+	}
+}
+
+func TestParserThemeFlags(t *testing.T) {
+	tests := []struct {
+		name     string
+		theme    ThemeConfig
+		flags    []string
+		md       string
+		expected string
+		missing  string
+	}{
+		{"smartypants", ThemeConfig{Text: "txt"}, []string{"smartypants", "smartypantsDashes"}, `"quoted" -- dash`, `<span class="txt">&ldquo;quoted&rdquo; &mdash; dash</span>`, ""},
+		{"escaped text", ThemeConfig{Text: "txt"}, nil, "a > b", `<span class="txt">a &gt; b</span>`, ""},
+		{"text in image alt", ThemeConfig{Text: "txt"}, nil, "![alt](/img.png)", `alt="alt"`, "<span"},
+		{"skip images", ThemeConfig{Image: "image"}, []string{"skipImages"}, "![alt](/img.png)", "<p></p>", "<img"},
+		{"lazy load images", ThemeConfig{Image: "image"}, []string{"lazyLoadImages"}, "![alt](/img.png)", `<img loading="lazy" src="/img.png" alt="alt" class="image" />`, ""},
+		{"skip html span", ThemeConfig{HTMLSpan: "raw"}, []string{"skipHTML"}, "a <b>b</b>", "<p>a b</p>", "<b>"},
+		{"skip html block", ThemeConfig{HTMLBlock: "raw"}, []string{"skipHTML"}, "<div>\nraw\n</div>\n", "", "<div"},
+		{"escaped class", ThemeConfig{Paragraph: "x", Emph: `a"b`}, nil, "*em*", `<em class="a&quot;b">em</em>`, `a"b`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := App{ThemeConfig: tt.theme}
+			app.Config.Markdown.Flags = tt.flags
+			output := string(app.markdownToHTML([]byte(tt.md)))
+			if !strings.Contains(output, tt.expected) {
+				t.Errorf("Expected output to contain %s, got: %s", tt.expected, output)
+			}
+			if tt.missing != "" && strings.Contains(output, tt.missing) {
+				t.Errorf("Expected output not to contain %s, got: %s", tt.missing, output)
+			}
+		})
+	}
+}
+
+func TestParserThemeUnconfigured(t *testing.T) {
+	md := []byte("# Title\n\nSome *text* with `code` and a [link](/).\n\n- item\n\n| a |\n|---|\n| 1 |\n")
+	themed := App{}.markdownToHTML(md)
+	doc := markdown.Parse(md, nil)
+	headingIDs(doc)
+	plain := markdown.Render(doc, html.NewRenderer(html.RendererOptions{Flags: html.FlagsNone}))
+	if string(themed) != string(plain) {
+		t.Errorf("Expected: %s, got: %s", plain, themed)
+	}
+}
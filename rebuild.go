@@ -0,0 +1,255 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// rebuild updates the dist directory after the file at fp changed. Only the
+// outputs that depend on fp are rebuilt:
+//
+//   - .squatch rebuilds the whole site
+//   - layout.html re-renders every page
+//   - layout_<name>.html re-renders the pages using that layout or a layout
+//     extending it, or every page if it was removed since pages may fall
+//     back to another layout
+//   - layout_default.html rebuilds the whole site since it decides which
+//     pages without a layout are rendered
+//   - partial_<name>.html re-renders every page
+//   - shortcode_<name>.html rebuilds the whole site since it is part of the
+//     page content
+//   - a markdown page re-renders itself, or every page if its title, url or
+//     params changed or it was added or removed since those are part of .Site
+//   - any other file is copied again
+//
+// Problems with single files are collected in the build report, which is
+// returned as the error if the rebuild failed.
+func (app *App) rebuild(fp string) error {
+	strict := app.Report != nil && app.Report.Strict
+	app.Report = &BuildReport{Strict: strict}
+	if err := app.rebuildFile(fp); err != nil {
+		return err
+	}
+	app.Report.Strict = strict
+	if err := app.Report.Err(); err != nil {
+		return err
+	}
+	app.Report.print(os.Stdout)
+	return nil
+}
+
+func (app *App) rebuildFile(fp string) error {
+	app.BuildTime = time.Now()
+	rel, err := filepath.Rel(app.SrcDir, fp)
+	if err != nil {
+		return err
+	}
+	if rel == ".squatch" {
+		return app.fullRebuild()
+	}
+	if app.isIgnoredPath(rel) {
+		return nil
+	}
+
+	info, err := os.Stat(fp)
+	removed := os.IsNotExist(err)
+	if err != nil && !removed {
+		return err
+	}
+	// Folders can hold any number of pages and layouts so rebuild everything
+	if !removed && info.IsDir() {
+		return app.fullRebuild()
+	}
+	if removed {
+		if info, err := os.Stat(filepath.Join(app.DistDir, rel)); err == nil && info.IsDir() {
+			return app.fullRebuild()
+		}
+	}
+
+	if _, ok := shortcodeName(fp); ok {
+		return app.fullRebuild()
+	}
+	if name, ok := partialName(fp); ok {
+		if removed {
+			delete(app.Partials, name)
+		} else if err := app.loadPartial(fp); err != nil {
+			return app.recordError(fp, err)
+		}
+		app.invalidateTemplates()
+		return app.renderPages()
+	}
+	if name, ok := layoutName(fp); ok {
+		if name == defaultLayout {
+			return app.fullRebuild()
+		}
+		// Removed layouts can change which layouts others extend
+		if removed {
+			app.removeLayout(fp)
+			app.invalidateTemplates()
+			return app.renderPages()
+		}
+		if err := app.loadLayout(fp); err != nil {
+			return app.recordError(fp, err)
+		}
+		if name == "" {
+			app.invalidateTemplates()
+			return app.renderPages()
+		}
+		key := layoutKey(app.relDir(fp), name)
+		app.invalidateLayout(key)
+		return app.renderLayout(key)
+	}
+	if filepath.Ext(fp) == ".md" {
+		return app.rebuildPage(fp, removed)
+	}
+	if removed {
+		return removeDistFile(filepath.Join(app.DistDir, rel))
+	}
+	return app.recordError(fp, app.copyFile(fp))
+}
+
+// fullRebuild reloads the config and source directory and renders every page
+func (app *App) fullRebuild() error {
+	newApp, err := InitApp(app.SrcDir)
+	if err != nil {
+		return err
+	}
+	*app = newApp
+	return app.renderSite()
+}
+
+// isIgnoredPath reports whether the file at rel, relative to the source
+// directory, is skipped when building
+func (app App) isIgnoredPath(rel string) bool {
+	parts := strings.Split(rel, string(filepath.Separator))
+	dir := app.SrcDir
+	for _, part := range parts[:len(parts)-1] {
+		dir = filepath.Join(dir, part)
+		if app.isIgnoredDir(dir) {
+			return true
+		}
+	}
+	name := parts[len(parts)-1]
+	if _, ok := app.IgnoreFiles[name]; ok {
+		return true
+	}
+	return app.isIgnoredDir(filepath.Join(app.SrcDir, rel))
+}
+
+// removeLayout removes the layout defined by the file at fp
+func (app *App) removeLayout(fp string) {
+	name, _ := layoutName(fp)
+	dir := app.relDir(fp)
+	if name != "" {
+		delete(app.Layouts, layoutKey(dir, name))
+	} else if dir != "" {
+		delete(app.SiteTemplates, dir)
+	} else {
+		app.SiteTemplate = ""
+	}
+}
+
+// renderLayout renders the pages and section listings that depend on the
+// layout key, directly or through a layout extending it
+func (app App) renderLayout(key string) error {
+	for _, page := range app.Pages {
+		layout, ok := app.pageLayout(page)
+		if !ok || !app.usesLayout(layout, key) {
+			continue
+		}
+		if err := app.renderPage(page); err != nil {
+			return err
+		}
+	}
+	for _, section := range app.sectionNames() {
+		config := app.Config.Sections[section]
+		layout, ok := app.resolveLayout(section, config.Layout)
+		if config.Type == postsSection && ok && app.usesLayout(layout, key) {
+			if err := app.renderSectionList(section, config); err != nil {
+				return err
+			}
+		}
+	}
+	for _, taxonomy := range taxonomies {
+		for _, name := range []string{taxonomyLayout, termLayout} {
+			layout, ok := app.resolveLayout(taxonomy, name)
+			if ok && app.usesLayout(layout, key) {
+				return app.renderTaxonomies()
+			}
+		}
+	}
+	return nil
+}
+
+// rebuildPage rereads the page at fp and renders it. Pages that were removed or
+// are no longer valid pages are removed from the dist directory.
+func (app *App) rebuildPage(fp string, removed bool) error {
+	index := -1
+	for i, page := range app.Pages {
+		if page.Filepath == fp {
+			index = i
+			break
+		}
+	}
+
+	var page Page
+	var err error
+	if !removed {
+		page, err = app.getPage(fp)
+		if invalid, ok := err.(InvalidPageError); ok {
+			app.skipPage(fp, invalid)
+		} else if err != nil {
+			return app.recordError(fp, err)
+		}
+	}
+	if removed || err != nil {
+		distPath, err := app.pageDistPath(fp)
+		if err != nil {
+			return err
+		}
+		if err := removeDistFile(distPath); err != nil {
+			return err
+		}
+		if index < 0 {
+			return nil
+		}
+		app.Pages = append(app.Pages[:index], app.Pages[index+1:]...)
+		app.indexPages()
+		return app.renderSite()
+	}
+
+	if index < 0 {
+		app.Pages = append(app.Pages, page)
+		app.indexPages()
+		return app.renderSite()
+	}
+	siteChanged := !sameSiteData(app.Pages[index], page)
+	app.Pages[index] = page
+	app.indexPages()
+	if siteChanged {
+		return app.renderSite()
+	}
+	if err := app.renderPage(app.Pages[index]); err != nil {
+		return err
+	}
+	// Feeds and the sitemap include the page content and modification time
+	return app.renderGenerated()
+}
+
+// sameSiteData reports whether the parts of a page listed in .Site.Pages that
+// other pages are likely to use are unchanged
+func sameSiteData(a Page, b Page) bool {
+	return a.Title == b.Title && a.URL == b.URL && a.Layout == b.Layout && reflect.DeepEqual(a.Params, b.Params)
+}
+
+// removeDistFile removes a built file, ignoring files that were never built
+func removeDistFile(fp string) error {
+	err := os.Remove(fp)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
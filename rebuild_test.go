@@ -0,0 +1,314 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newRebuildApp builds a copy of src_test into a temporary dist directory so
+// the tests can edit the source files
+func newRebuildApp(t *testing.T) *App {
+	t.Helper()
+	srcDir := filepath.Join(t.TempDir(), "src")
+	err := filepath.Walk("src_test", func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, _ := filepath.Rel("src_test", path)
+		if info.IsDir() {
+			return os.MkdirAll(filepath.Join(srcDir, rel), 0755)
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		return os.WriteFile(filepath.Join(srcDir, rel), data, 0644)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	config := map[string]interface{}{"dist": filepath.Join(t.TempDir(), "dist")}
+	writeJSON(t, filepath.Join(srcDir, ".squatch"), config)
+
+	app, err := InitApp(srcDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := app.renderPages(); err != nil {
+		t.Fatal(err)
+	}
+	return &app
+}
+
+func writeJSON(t *testing.T, fp string, v interface{}) {
+	t.Helper()
+	data, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(fp, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// markBuilt sets the modification time of the built files to the past so
+// rebuilt files can be told apart
+func markBuilt(t *testing.T, app *App) time.Time {
+	t.Helper()
+	old := time.Now().Add(-time.Hour)
+	filepath.Walk(app.DistDir, func(path string, info os.FileInfo, err error) error {
+		if err == nil && !info.IsDir() {
+			os.Chtimes(path, old, old)
+		}
+		return nil
+	})
+	return old
+}
+
+func wasRebuilt(t *testing.T, app *App, rel string, old time.Time) bool {
+	t.Helper()
+	info, err := os.Stat(filepath.Join(app.DistDir, rel))
+	if err != nil {
+		t.Fatalf("expected %v to exist, got %v", rel, err)
+	}
+	return info.ModTime().After(old)
+}
+
+func TestRebuildPage(t *testing.T) {
+	app := newRebuildApp(t)
+	old := markBuilt(t, app)
+	fp := filepath.Join(app.SrcDir, "pages", "example.md")
+	err := os.WriteFile(fp, []byte("[_metadata_:title]:- \"Example page title\"\n[_metadata_:layout]:- \"pages\"\n\nEdited body\n"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := app.rebuild(fp); err != nil {
+		t.Fatal(err)
+	}
+	if !wasRebuilt(t, app, filepath.Join("pages", "example.html"), old) {
+		t.Errorf("expected example.html to be rebuilt")
+	}
+	if wasRebuilt(t, app, "index.html", old) {
+		t.Errorf("expected index.html to not be rebuilt")
+	}
+	data, _ := os.ReadFile(filepath.Join(app.DistDir, "pages", "example.html"))
+	if !strings.Contains(string(data), "Edited body") {
+		t.Errorf("expected example.html to contain the edit, got %s", string(data))
+	}
+}
+
+func TestRebuildPageTitle(t *testing.T) {
+	app := newRebuildApp(t)
+	old := markBuilt(t, app)
+	fp := filepath.Join(app.SrcDir, "pages", "example.md")
+	err := os.WriteFile(fp, []byte("[_metadata_:title]:- \"Renamed\"\n[_metadata_:layout]:- \"pages\"\n\nBody\n"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := app.rebuild(fp); err != nil {
+		t.Fatal(err)
+	}
+	// The title is part of .Site.Pages so every page is rendered again
+	if !wasRebuilt(t, app, "index.html", old) {
+		t.Errorf("expected index.html to be rebuilt")
+	}
+	data, _ := os.ReadFile(filepath.Join(app.DistDir, "index.html"))
+	if !strings.Contains(string(data), "Renamed") {
+		t.Errorf("expected index.html to list the new title, got %s", string(data))
+	}
+}
+
+func TestRebuildNewAndRemovedPage(t *testing.T) {
+	app := newRebuildApp(t)
+	pageCount := len(app.Pages)
+	fp := filepath.Join(app.SrcDir, "pages", "new.md")
+	err := os.WriteFile(fp, []byte("---\ntitle: New\nlayout: pages\n---\n\nNew page\n"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := app.rebuild(fp); err != nil {
+		t.Fatal(err)
+	}
+	if len(app.Pages) != pageCount+1 {
+		t.Errorf("expected %d pages, got %d", pageCount+1, len(app.Pages))
+	}
+	if _, err := os.Stat(filepath.Join(app.DistDir, "pages", "new.html")); err != nil {
+		t.Errorf("expected new.html to exist, got %v", err)
+	}
+
+	os.Remove(fp)
+	if err := app.rebuild(fp); err != nil {
+		t.Fatal(err)
+	}
+	if len(app.Pages) != pageCount {
+		t.Errorf("expected %d pages, got %d", pageCount, len(app.Pages))
+	}
+	if _, err := os.Stat(filepath.Join(app.DistDir, "pages", "new.html")); err == nil {
+		t.Errorf("expected new.html to be removed")
+	}
+}
+
+func TestRebuildLayout(t *testing.T) {
+	app := newRebuildApp(t)
+	old := markBuilt(t, app)
+	fp := filepath.Join(app.SrcDir, "pages", "layout_pages.html")
+	if err := os.WriteFile(fp, []byte(`<div id="edited">{{.Body}}</div>`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := app.rebuild(fp); err != nil {
+		t.Fatal(err)
+	}
+	for _, rel := range []string{filepath.Join("pages", "example.html"), filepath.Join("pages", "frontmatter.html")} {
+		if !wasRebuilt(t, app, rel, old) {
+			t.Errorf("expected %v to be rebuilt", rel)
+		}
+	}
+	if wasRebuilt(t, app, "index.html", old) {
+		t.Errorf("expected index.html to not be rebuilt")
+	}
+}
+
+func TestRebuildSiteTemplate(t *testing.T) {
+	app := newRebuildApp(t)
+	old := markBuilt(t, app)
+	fp := filepath.Join(app.SrcDir, "layout.html")
+	if err := os.WriteFile(fp, []byte(`<main>{{.Body}}</main>`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := app.rebuild(fp); err != nil {
+		t.Fatal(err)
+	}
+	for _, page := range app.Pages {
+		distPath, _ := app.pageDistPath(page.Filepath)
+		rel, _ := filepath.Rel(app.DistDir, distPath)
+		if !wasRebuilt(t, app, rel, old) {
+			t.Errorf("expected %v to be rebuilt", rel)
+		}
+	}
+	if wasRebuilt(t, app, filepath.Join("static", "main.css"), old) {
+		t.Errorf("expected main.css to not be copied again")
+	}
+}
+
+func TestRebuildAsset(t *testing.T) {
+	app := newRebuildApp(t)
+	old := markBuilt(t, app)
+	fp := filepath.Join(app.SrcDir, "static", "main.css")
+	if err := os.WriteFile(fp, []byte("body { color: red; }"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := app.rebuild(fp); err != nil {
+		t.Fatal(err)
+	}
+	if !wasRebuilt(t, app, filepath.Join("static", "main.css"), old) {
+		t.Errorf("expected main.css to be copied again")
+	}
+	if wasRebuilt(t, app, "index.html", old) {
+		t.Errorf("expected index.html to not be rebuilt")
+	}
+
+	os.Remove(fp)
+	if err := app.rebuild(fp); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(app.DistDir, "static", "main.css")); err == nil {
+		t.Errorf("expected main.css to be removed")
+	}
+}
+
+func TestRebuildSquatchConfig(t *testing.T) {
+	app := newRebuildApp(t)
+	fp := filepath.Join(app.SrcDir, ".squatch")
+	config := map[string]interface{}{
+		"dist":  app.DistDir,
+		"theme": map[string]interface{}{"paragraph": "content"},
+	}
+	writeJSON(t, fp, config)
+	if err := app.rebuild(fp); err != nil {
+		t.Fatal(err)
+	}
+	if app.ThemeConfig.Paragraph != "content" {
+		t.Errorf("expected the theme config to be reloaded")
+	}
+	data, _ := os.ReadFile(filepath.Join(app.DistDir, "index.html"))
+	if !strings.Contains(string(data), `<p class="content">`) {
+		t.Errorf("expected index.html to be rebuilt with the new theme, got %s", string(data))
+	}
+}
+
+func TestRebuildIgnored(t *testing.T) {
+	app := newRebuildApp(t)
+	app.IgnoreFolders["drafts"] = true
+	fp := filepath.Join(app.SrcDir, "drafts", "draft.md")
+	os.MkdirAll(filepath.Dir(fp), 0755)
+	if err := os.WriteFile(fp, []byte("---\ntitle: Draft\nlayout: pages\n---\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := app.rebuild(fp); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(app.DistDir, "drafts", "draft.html")); err == nil {
+		t.Errorf("expected drafts to be ignored")
+	}
+}
+
+func TestRebuildExtendedLayout(t *testing.T) {
+	app := newRebuildApp(t)
+	old := markBuilt(t, app)
+	fp := filepath.Join(app.SrcDir, "layout_article.html")
+	if err := os.WriteFile(fp, []byte(`<article id="edited">{{.Body}}</article>`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := app.rebuild(fp); err != nil {
+		t.Fatal(err)
+	}
+	if !wasRebuilt(t, app, filepath.Join("pages", "guide.html"), old) {
+		t.Errorf("expected pages extending the layout to be rebuilt")
+	}
+	if wasRebuilt(t, app, "index.html", old) {
+		t.Errorf("expected index.html to not be rebuilt")
+	}
+}
+
+func TestRebuildPartial(t *testing.T) {
+	app := newRebuildApp(t)
+	old := markBuilt(t, app)
+	fp := filepath.Join(app.SrcDir, "partial_footer.html")
+	if err := os.WriteFile(fp, []byte(`<footer id="edited"></footer>`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := app.rebuild(fp); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(filepath.Join(app.DistDir, "pages", "guide.html"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), `<footer id="edited">`) {
+		t.Errorf("expected the edited partial in guide.html, got %v", string(data))
+	}
+	if !wasRebuilt(t, app, "index.html", old) {
+		t.Errorf("expected every page to be rebuilt")
+	}
+}
+
+func TestRebuildDefaultLayout(t *testing.T) {
+	app := newRebuildApp(t)
+	fp := filepath.Join(app.SrcDir, "nested", "layout_default.html")
+	if err := os.Remove(fp); err != nil {
+		t.Fatal(err)
+	}
+	if err := app.rebuild(fp); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(app.DistDir, "nested", "intro.html")); err == nil {
+		t.Errorf("expected pages using the removed default layout to no longer be built")
+	}
+	if _, ok := app.Layouts["nested/default"]; ok {
+		t.Errorf("expected the default layout to be removed")
+	}
+}
@@ -0,0 +1,243 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"html"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// searchScript is the bundled search widget written to search.js. It fills
+// #squatch-search-results with the pages matching #squatch-search. The url of
+// the manifest is filled in with fmt.Sprintf.
+const searchScript = `(function() {
+	var input = document.getElementById("squatch-search");
+	var results = document.getElementById("squatch-search-results");
+	if (!input || !results) {
+		return;
+	}
+	var index = null;
+
+	function load() {
+		if (index) {
+			return index;
+		}
+		index = fetch(%q).then(function(res) {
+			return res.json();
+		}).then(function(manifest) {
+			return Promise.all(manifest.shards.map(function(shard) {
+				return fetch(shard).then(function(res) {
+					return res.json();
+				});
+			}));
+		}).then(function(shards) {
+			return [].concat.apply([], shards);
+		});
+		return index;
+	}
+
+	function score(page, terms) {
+		var total = 0;
+		var title = page.title.toLowerCase();
+		var headings = page.headings.join(" ").toLowerCase();
+		var tags = page.tags.join(" ").toLowerCase();
+		var text = page.text.toLowerCase();
+		for (var i = 0; i < terms.length; i++) {
+			var term = terms[i];
+			var found = 0;
+			if (title.indexOf(term) >= 0) found += 10;
+			if (tags.indexOf(term) >= 0) found += 5;
+			if (headings.indexOf(term) >= 0) found += 3;
+			if (text.indexOf(term) >= 0) found += 1;
+			if (!found) {
+				return 0;
+			}
+			total += found;
+		}
+		return total;
+	}
+
+	function search() {
+		var terms = input.value.toLowerCase().split(/\s+/).filter(Boolean);
+		if (!terms.length) {
+			results.innerHTML = "";
+			return;
+		}
+		load().then(function(pages) {
+			var matches = pages.map(function(page) {
+				return {page: page, score: score(page, terms)};
+			}).filter(function(match) {
+				return match.score > 0;
+			}).sort(function(a, b) {
+				return b.score - a.score;
+			}).slice(0, 20);
+			results.innerHTML = "";
+			matches.forEach(function(match) {
+				var item = document.createElement("li");
+				var link = document.createElement("a");
+				link.href = match.page.url;
+				link.textContent = match.page.title;
+				item.appendChild(link);
+				results.appendChild(item);
+			});
+		});
+	}
+
+	input.addEventListener("input", search);
+})();
+`
+
+var (
+	headingTag   = regexp.MustCompile(`(?s)<h[1-6][^>]*>(.*?)</h[1-6]>`)
+	htmlTag      = regexp.MustCompile(`(?s)<[^>]*>`)
+	extraSpacing = regexp.MustCompile(`\s+`)
+)
+
+// SearchEntry is a page in the search index
+type SearchEntry struct {
+	Title    string   `json:"title"`
+	URL      string   `json:"url"`
+	Headings []string `json:"headings"`
+	Text     string   `json:"text"`
+	Tags     []string `json:"tags"`
+}
+
+// searchManifest lists the shards of the search index
+type searchManifest struct {
+	Pages  int      `json:"pages"`
+	Shards []string `json:"shards"`
+}
+
+// permalinkTag returns a pattern matching the heading permalinks written by
+// renderPermalink, or nil when headings have no permalinks. Other links are
+// indexed like the rest of the page, even hidden ones.
+func permalinkTag(config AnchorConfig) *regexp.Regexp {
+	if !config.Permalink {
+		return nil
+	}
+	return regexp.MustCompile(`(?s) <a ` + regexp.QuoteMeta(classAttr(config.permalinkClass())) + ` href="#[^"]*" aria-hidden="true">.*?</a>`)
+}
+
+// stripTags returns the text of an html fragment
+func stripTags(s string) string {
+	s = htmlTag.ReplaceAllString(s, " ")
+	s = html.UnescapeString(s)
+	return strings.TrimSpace(extraSpacing.ReplaceAllString(s, " "))
+}
+
+// searchEntry returns the index entry of page, leaving out the heading
+// permalinks matched by permalink
+func searchEntry(page Page, permalink *regexp.Regexp) SearchEntry {
+	body := string(page.Body)
+	if permalink != nil {
+		body = permalink.ReplaceAllString(body, "")
+	}
+	entry := SearchEntry{
+		Title:    page.Title,
+		URL:      page.URL,
+		Headings: []string{},
+		Text:     stripTags(body),
+		Tags:     pageTerms(page, "tags"),
+	}
+	for _, match := range headingTag.FindAllStringSubmatch(body, -1) {
+		entry.Headings = append(entry.Headings, stripTags(match[1]))
+	}
+	if entry.Tags == nil {
+		entry.Tags = []string{}
+	}
+	return entry
+}
+
+// inSearch reports whether a page is in the search index. Pages can opt out
+// with a search param set to false.
+func (app App) inSearch(page Page) bool {
+	if included, ok := page.Params["search"].(bool); ok && !included {
+		return false
+	}
+	_, ok := app.pageLayout(page)
+	return ok
+}
+
+// renderSearch writes the search index when search is configured. The index
+// is split into shards under /search/ listed by /search/index.json, along
+// with the search widget at /search.js. Urls in the index include the path of
+// baseURL.
+func (app App) renderSearch() error {
+	config := app.Config.Search
+	if config == nil {
+		return nil
+	}
+	entries := []SearchEntry{}
+	permalink := permalinkTag(app.Config.Anchors)
+	for _, page := range app.Pages {
+		if app.inSearch(page) {
+			entry := searchEntry(page, permalink)
+			entry.URL = app.relURL(page.URL)
+			entries = append(entries, entry)
+		}
+	}
+
+	// Without a shard size the whole index is a single shard
+	shardSize := config.ShardSize
+	if shardSize <= 0 {
+		shardSize = len(entries)
+	}
+	searchDir := filepath.Join(app.DistDir, "search")
+	previous := readSearchManifest(filepath.Join(searchDir, "index.json"))
+	if err := os.MkdirAll(searchDir, 0755); err != nil {
+		return app.recordError(searchDir, err)
+	}
+	manifest := searchManifest{Pages: len(entries), Shards: []string{}}
+	for start := 0; start < len(entries) || start == 0; start += shardSize {
+		end := start + shardSize
+		if end > len(entries) {
+			end = len(entries)
+		}
+		name := fmt.Sprintf("%d.json", len(manifest.Shards))
+		fp := filepath.Join(searchDir, name)
+		if err := app.recordError(fp, writeJSONFile(fp, entries[start:end])); err != nil {
+			return err
+		}
+		manifest.Shards = append(manifest.Shards, app.relURL("/search/"+name))
+		if shardSize == 0 {
+			break
+		}
+	}
+	// Remove the shards left over from a bigger index, leaving any other
+	// files in the folder
+	for i := len(manifest.Shards); i < len(previous.Shards); i++ {
+		fp := filepath.Join(searchDir, path.Base(previous.Shards[i]))
+		if err := app.recordError(fp, removeDistFile(fp)); err != nil {
+			return err
+		}
+	}
+	fp := filepath.Join(searchDir, "index.json")
+	if err := app.recordError(fp, writeJSONFile(fp, manifest)); err != nil {
+		return err
+	}
+	script := fmt.Sprintf(searchScript, app.relURL("/search/index.json"))
+	fp = filepath.Join(app.DistDir, "search.js")
+	return app.recordError(fp, os.WriteFile(fp, []byte(script), 0644))
+}
+
+// readSearchManifest reads the manifest of a previous build, or returns an
+// empty manifest if there is none
+func readSearchManifest(fp string) searchManifest {
+	var manifest searchManifest
+	if data, err := os.ReadFile(fp); err == nil {
+		json.Unmarshal(data, &manifest)
+	}
+	return manifest
+}
+
+func writeJSONFile(fp string, v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(fp, data, 0644)
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestStripTags(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"<p>Hello <strong>world</strong></p>", "Hello world"},
+		{"<p>Fish &amp; chips</p>\n\n<p>Peas</p>", "Fish & chips Peas"},
+		{"plain", "plain"},
+	}
+	for _, test := range tests {
+		if got := stripTags(test.input); got != test.expected {
+			t.Errorf("stripTags(%q) = %q, expected %q", test.input, got, test.expected)
+		}
+	}
+}
+
+func TestSearchEntry(t *testing.T) {
+	page := Page{
+		Title:  "Post",
+		URL:    "/post.html",
+		Body:   `<h1 class="title">Intro</h1><p>Some text</p><h2>More <em>details</em></h2>`,
+		Params: map[string]interface{}{"tags": []interface{}{"Go"}},
+	}
+	entry := searchEntry(page, nil)
+	if !reflect.DeepEqual(entry.Headings, []string{"Intro", "More details"}) {
+		t.Errorf("expected the page headings, got %v", entry.Headings)
+	}
+	if entry.Text != "Intro Some text More details" {
+		t.Errorf("expected the page text, got %q", entry.Text)
+	}
+	if !reflect.DeepEqual(entry.Tags, []string{"Go"}) {
+		t.Errorf("expected the page tags, got %v", entry.Tags)
+	}
+}
+
+func TestSearchEntryPermalinks(t *testing.T) {
+	page := Page{
+		Title: "Post",
+		Body:  `<h2 id="usage" class="title">Usage <a class="anchor" href="#usage" aria-hidden="true">#</a></h2>` + "\n" + `<p>Press <a class="key" href="#keys" aria-hidden="true">Enter</a></p>`,
+	}
+	tests := []struct {
+		config   AnchorConfig
+		headings []string
+		text     string
+	}{
+		{AnchorConfig{Permalink: true}, []string{"Usage"}, "Usage Press Enter"},
+		{AnchorConfig{Permalink: true, Class: "key"}, []string{"Usage #"}, "Usage # Press"},
+		{AnchorConfig{}, []string{"Usage #"}, "Usage # Press Enter"},
+	}
+	for _, tt := range tests {
+		entry := searchEntry(page, permalinkTag(tt.config))
+		if !reflect.DeepEqual(entry.Headings, tt.headings) {
+			t.Errorf("expected headings %v with %+v, got %v", tt.headings, tt.config, entry.Headings)
+		}
+		if entry.Text != tt.text {
+			t.Errorf("expected text %q with %+v, got %q", tt.text, tt.config, entry.Text)
+		}
+	}
+}
+
+func TestRenderSearch(t *testing.T) {
+	srcTest := "src_test"
+	defer cleanup("dist")
+	Build(srcTest)
+	data, err := os.ReadFile(filepath.Join("dist", "search", "index.json"))
+	if err != nil {
+		t.Fatalf("expected search/index.json to exist, got %v", err)
+	}
+	var manifest searchManifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		t.Fatalf("expected a valid manifest, got %v", err)
+	}
+	if len(manifest.Shards) < 2 {
+		t.Errorf("expected the index to be sharded, got %v", manifest.Shards)
+	}
+	entries := []SearchEntry{}
+	for _, shard := range manifest.Shards {
+		// Urls include the path of the baseURL
+		data, err := os.ReadFile(filepath.Join("dist", filepath.FromSlash(strings.TrimPrefix(shard, "/docs"))))
+		if err != nil {
+			t.Fatalf("expected shard %v to exist, got %v", shard, err)
+		}
+		var shardEntries []SearchEntry
+		if err := json.Unmarshal(data, &shardEntries); err != nil {
+			t.Fatalf("expected shard %v to be valid json, got %v", shard, err)
+		}
+		if len(shardEntries) > 4 {
+			t.Errorf("expected at most 4 pages per shard, got %v", len(shardEntries))
+		}
+		entries = append(entries, shardEntries...)
+	}
+	if len(entries) != manifest.Pages {
+		t.Errorf("expected %v pages, got %v", manifest.Pages, len(entries))
+	}
+	found := false
+	for _, entry := range entries {
+		if entry.URL == "/docs/blog/third.html" {
+			found = true
+			if !reflect.DeepEqual(entry.Tags, []string{"Go", "markdown"}) {
+				t.Errorf("expected the post tags, got %v", entry.Tags)
+			}
+		}
+	}
+	if !found {
+		t.Errorf("expected /docs/blog/third.html in the index")
+	}
+	script, err := os.ReadFile(filepath.Join("dist", "search.js"))
+	if err != nil {
+		t.Fatalf("expected search.js to exist, got %v", err)
+	}
+	if !strings.Contains(string(script), `fetch("/docs/search/index.json")`) {
+		t.Errorf("expected search.js to load the manifest under the baseURL, got %s", script)
+	}
+}
+
+func TestRenderSearchKeepsOtherFiles(t *testing.T) {
+	srcDir := writeSite(t, `"search": {"shardSize": 1}`, map[string]string{
+		"layout.html":         "{{.Body}}",
+		"one.md":              "---\ntitle: One\nlayout: default\n---\n",
+		"two.md":              "---\ntitle: Two\nlayout: default\n---\n",
+		"layout_default.html": "{{.Body}}",
+		"search/index.md":     "---\ntitle: Search\nlayout: default\nsearch: false\n---\n",
+		"search/results.css":  "ul {}",
+	})
+	app, err := InitApp(srcDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := app.renderSite(); err != nil {
+		t.Fatal(err)
+	}
+	searchDir := filepath.Join(app.DistDir, "search")
+	for _, name := range []string{"index.html", "results.css", "0.json", "1.json"} {
+		if _, err := os.Stat(filepath.Join(searchDir, name)); err != nil {
+			t.Errorf("expected search/%v to exist, got %v", name, err)
+		}
+	}
+	// A smaller index removes its stale shards only
+	app.Pages = app.Pages[:1]
+	if err := app.renderSearch(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(searchDir, "1.json")); err == nil {
+		t.Errorf("expected the stale shard to be removed")
+	}
+	for _, name := range []string{"index.html", "results.css", "0.json"} {
+		if _, err := os.Stat(filepath.Join(searchDir, name)); err != nil {
+			t.Errorf("expected search/%v to be kept, got %v", name, err)
+		}
+	}
+}
@@ -0,0 +1,204 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// postsSection is the section type for dated posts with paginated listings
+const postsSection = "posts"
+
+// defaultPerPage is the number of posts on a list page when not configured
+const defaultPerPage = 10
+
+// datedFilename matches posts named like 2023-01-15-my-post.md
+var datedFilename = regexp.MustCompile(`^(\d{4}-\d{2}-\d{2})-`)
+
+// dateFormats are the layouts accepted for dates written as strings
+var dateFormats = []string{time.RFC3339, "2006-01-02T15:04:05", "2006-01-02 15:04:05", "2006-01-02"}
+
+// Paginator is the data for one page of a section listing, available to the
+// list layout as .Paginator
+type Paginator struct {
+	Posts      []Page
+	PageNumber int
+	TotalPages int
+	PrevURL    string
+	NextURL    string
+}
+
+// pageDate returns the date of a page from its date param or, failing that,
+// a date at the start of its filename
+func pageDate(fp string, params map[string]interface{}) time.Time {
+	if date := paramDate(params["date"]); !date.IsZero() {
+		return date
+	}
+	if match := datedFilename.FindStringSubmatch(filepath.Base(fp)); match != nil {
+		if t, ok := parseDate(match[1]); ok {
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+// paramDate converts a date param to a time, or the zero time if it is not a date
+func paramDate(value interface{}) time.Time {
+	switch date := value.(type) {
+	case time.Time:
+		return date
+	case string:
+		if t, ok := parseDate(date); ok {
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+func parseDate(date string) (time.Time, bool) {
+	for _, format := range dateFormats {
+		if t, err := time.Parse(format, strings.TrimSpace(date)); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// sectionOf returns the configured section containing the page at fp, using
+// the deepest matching folder
+func (app App) sectionOf(fp string) string {
+	rel, err := filepath.Rel(app.SrcDir, fp)
+	if err != nil {
+		return ""
+	}
+	rel = filepath.ToSlash(rel)
+	section := ""
+	for name := range app.Config.Sections {
+		if strings.HasPrefix(rel, name+"/") && len(name) > len(section) {
+			section = name
+		}
+	}
+	return section
+}
+
+// indexPages groups the pages into their sections and taxonomies, sorts posts
+// newest first and links each post to its neighbours. It has to run whenever
+// app.Pages changes.
+func (app *App) indexPages() {
+	app.Sections = map[string][]Page{}
+	byPath := map[string]int{}
+	for i := range app.Pages {
+		app.Pages[i].Section = app.sectionOf(app.Pages[i].Filepath)
+		app.Pages[i].Prev = nil
+		app.Pages[i].Next = nil
+		byPath[app.Pages[i].Filepath] = i
+	}
+	for name, config := range app.Config.Sections {
+		pages := []Page{}
+		for _, page := range app.Pages {
+			if page.Section == name {
+				pages = append(pages, page)
+			}
+		}
+		app.Sections[name] = pages
+		if config.Type != postsSection {
+			continue
+		}
+		sort.SliceStable(pages, func(i, j int) bool {
+			return pages[i].Date.After(pages[j].Date)
+		})
+		// Prev is the older post and Next is the newer one
+		for i, post := range pages {
+			index := byPath[post.Filepath]
+			if i+1 < len(pages) {
+				app.Pages[index].Prev = &pages[i+1]
+			}
+			if i > 0 {
+				app.Pages[index].Next = &pages[i-1]
+			}
+		}
+	}
+	app.indexTaxonomies()
+}
+
+// sectionNames returns the configured section names in a stable order
+func (app App) sectionNames() []string {
+	names := make([]string, 0, len(app.Config.Sections))
+	for name := range app.Config.Sections {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// sectionListURL returns the url of a page of a section listing
+func sectionListURL(section string, number int) string {
+	if number <= 1 {
+		return "/" + section + "/"
+	}
+	return fmt.Sprintf("/%v/page/%d/", section, number)
+}
+
+// renderSectionLists renders the paginated listings of every posts section
+// that has a list layout, at /<section>/ and /<section>/page/<n>/
+func (app App) renderSectionLists() error {
+	for _, name := range app.sectionNames() {
+		config := app.Config.Sections[name]
+		if config.Type != postsSection || config.Layout == "" {
+			continue
+		}
+		if err := app.renderSectionList(name, config); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (app App) renderSectionList(name string, config SectionConfig) error {
+	posts := app.Sections[name]
+	perPage := config.PerPage
+	if perPage <= 0 {
+		perPage = defaultPerPage
+	}
+	totalPages := (len(posts) + perPage - 1) / perPage
+	if totalPages == 0 {
+		totalPages = 1
+	}
+	title := config.Title
+	if title == "" {
+		title = name
+	}
+
+	return forEach(totalPages, func(i int) error {
+		number := i + 1
+		start := (number - 1) * perPage
+		end := start + perPage
+		if end > len(posts) {
+			end = len(posts)
+		}
+		paginator := &Paginator{
+			Posts:      posts[start:end],
+			PageNumber: number,
+			TotalPages: totalPages,
+		}
+		if number > 1 {
+			paginator.PrevURL = sectionListURL(name, number-1)
+		}
+		if number < totalPages {
+			paginator.NextURL = sectionListURL(name, number+1)
+		}
+		page := Page{
+			Title:     title,
+			Layout:    config.Layout,
+			URL:       sectionListURL(name, number),
+			Params:    map[string]interface{}{},
+			Section:   name,
+			Paginator: paginator,
+		}
+		fp := filepath.Join(app.DistDir, filepath.FromSlash(page.URL), "index.html")
+		return app.recordError(page.URL, app.renderPageTo(page, fp))
+	})
+}
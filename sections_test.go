@@ -0,0 +1,120 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPageDate(t *testing.T) {
+	expected := time.Date(2023, 1, 15, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name   string
+		fp     string
+		params map[string]interface{}
+	}{
+		{"time param", "post.md", map[string]interface{}{"date": expected}},
+		{"string param", "post.md", map[string]interface{}{"date": "2023-01-15"}},
+		{"rfc3339 param", "post.md", map[string]interface{}{"date": "2023-01-15T00:00:00Z"}},
+		{"filename", filepath.Join("blog", "2023-01-15-post.md"), map[string]interface{}{}},
+		{"param over filename", "2020-01-01-post.md", map[string]interface{}{"date": "2023-01-15"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if date := pageDate(tt.fp, tt.params); !date.Equal(expected) {
+				t.Errorf("expected %v, got %v", expected, date)
+			}
+		})
+	}
+	if date := pageDate("post.md", map[string]interface{}{"date": "someday"}); !date.IsZero() {
+		t.Errorf("expected no date, got %v", date)
+	}
+}
+
+func TestSectionListURL(t *testing.T) {
+	if url := sectionListURL("blog", 1); url != "/blog/" {
+		t.Errorf("expected /blog/, got %v", url)
+	}
+	if url := sectionListURL("blog", 3); url != "/blog/page/3/" {
+		t.Errorf("expected /blog/page/3/, got %v", url)
+	}
+}
+
+func TestIndexPages(t *testing.T) {
+	srcTest := "src_test"
+	app, err := InitApp(srcTest)
+	defer cleanup(app.DistDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	posts := app.Sections["blog"]
+	if len(posts) != 3 {
+		t.Fatalf("expected 3 posts, got %d", len(posts))
+	}
+	for i, title := range []string{"Third Post", "Second Post", "First Post"} {
+		if posts[i].Title != title {
+			t.Errorf("expected post %d to be %v, got %v", i, title, posts[i].Title)
+		}
+	}
+	for _, page := range app.Pages {
+		switch page.Title {
+		case "Second Post":
+			if page.Prev == nil || page.Prev.Title != "First Post" {
+				t.Errorf("expected previous post to be First Post, got %v", page.Prev)
+			}
+			if page.Next == nil || page.Next.Title != "Third Post" {
+				t.Errorf("expected next post to be Third Post, got %v", page.Next)
+			}
+		case "Squatch":
+			if page.Section != "" || page.Prev != nil || page.Next != nil {
+				t.Errorf("expected pages outside sections to not be linked")
+			}
+		}
+	}
+}
+
+func TestRenderSectionLists(t *testing.T) {
+	srcTest := "src_test"
+	defer cleanup("dist")
+	Build(srcTest)
+	data, err := os.ReadFile(filepath.Join("dist", "blog", "index.html"))
+	if err != nil {
+		t.Fatalf("expected blog/index.html to exist, got %v", err)
+	}
+	first := string(data)
+	if !strings.Contains(first, `<a class="post" href="/blog/third.html">Third Post</a><a class="post" href="/blog/2023-02-01-second.html">Second Post</a>`) {
+		t.Errorf("expected the two newest posts on the first page, got %s", first)
+	}
+	if !strings.Contains(first, `<a class="next" href="/blog/page/2/">Older</a>`) || strings.Contains(first, `class="prev"`) {
+		t.Errorf("expected only a link to the next page, got %s", first)
+	}
+
+	data, err = os.ReadFile(filepath.Join("dist", "blog", "page", "2", "index.html"))
+	if err != nil {
+		t.Fatalf("expected blog/page/2/index.html to exist, got %v", err)
+	}
+	second := string(data)
+	if !strings.Contains(second, `<a class="post" href="/blog/2023-01-01-first.html">First Post</a>`) {
+		t.Errorf("expected the oldest post on the second page, got %s", second)
+	}
+	if !strings.Contains(second, `<a class="prev" href="/blog/">Newer</a>`) {
+		t.Errorf("expected a link to the first page, got %s", second)
+	}
+
+	data, err = os.ReadFile(filepath.Join("dist", "blog", "2023-02-01-second.html"))
+	if err != nil {
+		t.Fatalf("expected the second post to exist, got %v", err)
+	}
+	post := string(data)
+	if !strings.Contains(post, "<time>2023-02-01</time>") {
+		t.Errorf("expected the post date from the filename, got %s", post)
+	}
+	if !strings.Contains(post, `<a class="prev" href="/blog/2023-01-01-first.html">First Post</a>`) {
+		t.Errorf("expected a link to the previous post, got %s", post)
+	}
+	if !strings.Contains(post, `<a class="next" href="/blog/third.html">Third Post</a>`) {
+		t.Errorf("expected a link to the next post, got %s", post)
+	}
+}
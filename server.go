@@ -1,6 +1,7 @@
 package main
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
 	"io"
@@ -8,14 +9,16 @@ import (
 	"math"
 	"net/http"
 	"os"
+	"path"
 	"path/filepath"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/fsnotify/fsnotify"
 )
 
-func filewatch(srcDir string, distDir string) {
+func (app *App) filewatch(reload *liveReload) {
 	// Create new watcher.
 	watcher, err := fsnotify.NewWatcher()
 	if err != nil {
@@ -24,17 +27,77 @@ func filewatch(srcDir string, distDir string) {
 	defer watcher.Close()
 
 	// Start listening for events.
-	go watchLoop(watcher, srcDir, distDir)
+	go app.watchLoop(watcher, reload)
 
-	// Add a path.
-	err = watcher.Add(srcDir)
+	// Add the source directory and every folder beneath it since fsnotify
+	// does not watch recursively.
+	err = app.watchDir(watcher, app.SrcDir)
 	if err != nil {
 		log.Fatal(err)
 	}
 	<-make(chan struct{}) // Block forever
 }
 
-func watchLoop(w *fsnotify.Watcher, srcDir string, distDir string) {
+// watchDir adds root and all of its non-ignored subdirectories to the watcher
+func (app App) watchDir(w *fsnotify.Watcher, root string) error {
+	return filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() {
+			return nil
+		}
+		if app.isIgnoredDir(path) {
+			return filepath.SkipDir
+		}
+		return w.Add(path)
+	})
+}
+
+// unwatchDir removes root and any watched folders beneath it from the watcher
+func unwatchDir(w *fsnotify.Watcher, root string) {
+	prefix := root + string(filepath.Separator)
+	for _, path := range w.WatchList() {
+		if path == root || strings.HasPrefix(path, prefix) {
+			// The folder may already be gone from the watcher if it was deleted
+			w.Remove(path)
+		}
+	}
+}
+
+// updateWatchList keeps the watcher in sync with folders being created,
+// deleted and renamed in the source directory
+func (app App) updateWatchList(w *fsnotify.Watcher, e fsnotify.Event) {
+	if e.Has(fsnotify.Create) {
+		if info, err := os.Stat(e.Name); err == nil && info.IsDir() {
+			if err := app.watchDir(w, e.Name); err != nil {
+				log.Printf("error watching %v: %v", e.Name, err)
+			}
+		}
+	}
+	if e.Has(fsnotify.Remove) || e.Has(fsnotify.Rename) {
+		unwatchDir(w, e.Name)
+	}
+}
+
+// refreshWatchList stops watching folders that became ignored and watches the
+// ones that no longer are, since a .squatch change can change the ignore lists
+func (app App) refreshWatchList(w *fsnotify.Watcher) {
+	for _, path := range w.WatchList() {
+		if app.isIgnoredDir(path) {
+			unwatchDir(w, path)
+		}
+	}
+	if err := app.watchDir(w, app.SrcDir); err != nil {
+		log.Printf("error watching %v: %v", app.SrcDir, err)
+	}
+}
+
+// buildMu lets one rebuild update the live server's app at a time and keeps
+// requests from reading the app while it changes
+var buildMu sync.RWMutex
+
+func (app *App) watchLoop(w *fsnotify.Watcher, reload *liveReload) {
 	var (
 		// Wait 100ms for new events; each new event resets the timer.
 		waitFor = 100 * time.Millisecond
@@ -45,12 +108,18 @@ func watchLoop(w *fsnotify.Watcher, srcDir string, distDir string) {
 
 		// Callback we run.
 		buildEvent = func(e fsnotify.Event) {
-			// Ignore the build directory
-			baseDir := filepath.Base(e.Name)
-			if baseDir == distDir {
-				return
+			// Keep the server running on a failed build so the error can be fixed
+			buildMu.Lock()
+			err := app.rebuild(e.Name)
+			if e.Name == filepath.Join(app.SrcDir, ".squatch") {
+				app.refreshWatchList(w)
+			}
+			buildMu.Unlock()
+			if err != nil {
+				log.Printf("build error: %v", err)
+			} else {
+				reload.notify(reloadEventFor(e.Name))
 			}
-			Build(srcDir)
 
 			// Don't need to remove the timer if you don't have a lot of files.
 			mu.Lock()
@@ -73,6 +142,9 @@ func watchLoop(w *fsnotify.Watcher, srcDir string, distDir string) {
 				return
 			}
 			log.Printf("event: %v", e)
+			buildMu.Lock()
+			app.updateWatchList(w, e)
+			buildMu.Unlock()
 
 			// Get timer.
 			mu.Lock()
@@ -103,54 +175,93 @@ func checkLive(w http.ResponseWriter, err error) {
 	}
 }
 
-func (app App) getLivePage(w http.ResponseWriter, r *http.Request) {
-	// get the path from the url
-	cleanPath := filepath.Clean(r.URL.Path)
-	fp := filepath.Join(app.DistDir, cleanPath+".html")
-
-	// return the index page if the path is empty
-	if cleanPath == "/" {
-		fp = filepath.Join(app.DistDir, "index.html")
+// resolveLivePath maps a request path to a file in the dist directory. Files
+// are served as is, directories serve their index.html and anything else is
+// treated as a pretty url for a rendered page.
+func (app App) resolveLivePath(urlPath string) (string, bool) {
+	// Cleaning the rooted path keeps the request inside the dist directory
+	cleanPath := path.Clean("/" + urlPath)
+	fp := filepath.Join(app.DistDir, filepath.FromSlash(cleanPath))
+	candidates := []string{fp, filepath.Join(fp, "index.html"), fp + ".html"}
+	for _, candidate := range candidates {
+		info, err := os.Stat(candidate)
+		if err == nil && !info.IsDir() {
+			return candidate, true
+		}
 	}
+	return "", false
+}
 
-	// 404 if the requested page doesn't exist
-	if _, err := os.Stat(fp); os.IsNotExist(err) {
+// getLivePage serves a file from the dist directory. It reads the app on
+// every request since a rebuild can replace it, like a .squatch change moving
+// the dist directory.
+func (app *App) getLivePage(w http.ResponseWriter, r *http.Request) {
+	buildMu.RLock()
+	fp, ok := app.resolveLivePath(r.URL.Path)
+	buildMu.RUnlock()
+	if !ok {
 		http.NotFound(w, r)
 		return
 	}
 
-	// 404 if the requested page is a directory
-	if info, err := os.Stat(fp); err == nil && info.IsDir() {
-		http.NotFound(w, r)
+	f, err := os.Open(fp)
+	if err != nil {
+		checkLive(w, err)
 		return
 	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		checkLive(w, err)
+		return
+	}
+	var content io.ReadSeeker = f
+	if filepath.Ext(fp) == ".html" {
+		page, err := io.ReadAll(f)
+		if err != nil {
+			checkLive(w, err)
+			return
+		}
+		content = bytes.NewReader(injectLiveReload(page))
+	}
 
-	// read the markdown file
-	fileData, err := os.ReadFile(fp)
-	checkLive(w, err)
-
-	w.Header().Set("Content-Type", "text/html")
-	w.Write(fileData)
+	// Pages change on every rebuild so make the browser revalidate each time
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("ETag", fmt.Sprintf(`"%x-%x"`, info.ModTime().UnixNano(), info.Size()))
+	// ServeContent sets the content type and handles range and conditional requests
+	http.ServeContent(w, r, info.Name(), info.ModTime(), content)
 }
 
 func ping(w http.ResponseWriter, r *http.Request) {
 	io.WriteString(w, "pong")
 }
 
-func LiveServer(srcDir string, port string) {
+// LiveServer builds srcDir and serves it on port, rebuilding on changes. In a
+// strict build warnings fail the rebuilds too.
+func LiveServer(srcDir string, port string, strict bool) {
 	app, err := InitApp(srcDir)
 	if err != nil {
 		log.Fatal(err)
 		panic(err)
 	}
+	app.Report.Strict = strict
 
-	go filewatch(app.SrcDir, app.DistDir)
-	Build(app.SrcDir)
+	err = app.renderSite()
+	if err != nil {
+		log.Fatal(err)
+	}
+	// Keep serving with problems in single files so they can be fixed
+	app.Report.print(os.Stdout)
 
 	// serve pages
+	reload := newLiveReload()
 	mux := http.NewServeMux()
 	mux.HandleFunc("/", app.getLivePage)
 	mux.HandleFunc("/ping", ping)
+	mux.Handle(liveReloadPath, reload)
+
+	// Rebuild only what changed after the initial build
+	go app.filewatch(reload)
 	err = http.ListenAndServe(":"+port, mux)
 
 	// handle server closing
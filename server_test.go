@@ -3,7 +3,12 @@ package main
 import (
 	"io"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+
+	"github.com/fsnotify/fsnotify"
 )
 
 func TestPing(t *testing.T) {
@@ -70,3 +75,239 @@ func TestGetLivePage(t *testing.T) {
 		t.Fatalf("expected about, got %s", string(data))
 	}
 }
+
+func TestGetLivePageStatic(t *testing.T) {
+	srcDir := "src_test"
+	defer cleanup("dist")
+	app, err := InitApp(srcDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req := httptest.NewRequest("GET", "/static/main.css", nil)
+	w := httptest.NewRecorder()
+	app.getLivePage(w, req)
+	res := w.Result()
+	defer res.Body.Close()
+	if res.StatusCode != 200 {
+		t.Fatalf("expected 200, got %d", res.StatusCode)
+	}
+	if !strings.HasPrefix(res.Header.Get("Content-Type"), "text/css") {
+		t.Fatalf("expected text/css, got %s", res.Header.Get("Content-Type"))
+	}
+	if res.Header.Get("ETag") == "" {
+		t.Fatalf("expected an ETag header")
+	}
+
+	// A matching ETag should not resend the file
+	req = httptest.NewRequest("GET", "/static/main.css", nil)
+	req.Header.Set("If-None-Match", res.Header.Get("ETag"))
+	w = httptest.NewRecorder()
+	app.getLivePage(w, req)
+	if w.Code != 304 {
+		t.Fatalf("expected 304, got %d", w.Code)
+	}
+}
+
+func TestGetLivePageRange(t *testing.T) {
+	app := App{DistDir: t.TempDir()}
+	err := os.WriteFile(filepath.Join(app.DistDir, "data.txt"), []byte("0123456789"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req := httptest.NewRequest("GET", "/data.txt", nil)
+	req.Header.Set("Range", "bytes=2-4")
+	w := httptest.NewRecorder()
+	app.getLivePage(w, req)
+	if w.Code != 206 {
+		t.Fatalf("expected 206, got %d", w.Code)
+	}
+	if w.Body.String() != "234" {
+		t.Fatalf("expected 234, got %s", w.Body.String())
+	}
+}
+
+func TestGetLivePageDirectoryIndex(t *testing.T) {
+	app := App{DistDir: t.TempDir()}
+	err := os.MkdirAll(filepath.Join(app.DistDir, "docs"), 0755)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = os.WriteFile(filepath.Join(app.DistDir, "docs", "index.html"), []byte("docs index"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range []string{"/docs", "/docs/"} {
+		req := httptest.NewRequest("GET", p, nil)
+		w := httptest.NewRecorder()
+		app.getLivePage(w, req)
+		if w.Code != 200 {
+			t.Fatalf("expected 200 for %s, got %d", p, w.Code)
+		}
+		if !strings.HasPrefix(w.Body.String(), "docs index") {
+			t.Fatalf("expected docs index for %s, got %s", p, w.Body.String())
+		}
+		if !strings.HasPrefix(w.Header().Get("Content-Type"), "text/html") {
+			t.Fatalf("expected text/html for %s, got %s", p, w.Header().Get("Content-Type"))
+		}
+	}
+}
+
+func TestGetLivePageNotFound(t *testing.T) {
+	app := App{DistDir: t.TempDir()}
+	for _, p := range []string{"/missing", "/../server.go"} {
+		req := httptest.NewRequest("GET", p, nil)
+		w := httptest.NewRecorder()
+		app.getLivePage(w, req)
+		if w.Code != 404 {
+			t.Fatalf("expected 404 for %s, got %d", p, w.Code)
+		}
+	}
+}
+
+func TestGetLivePageAfterConfigChange(t *testing.T) {
+	srcDir := writeSite(t, "", map[string]string{
+		"layout.html":      "{{.Body}}",
+		"layout_page.html": "{{.Body}}",
+		"page.md":          "---\ntitle: Page\nlayout: page\n---\nHello",
+	})
+	app, err := InitApp(srcDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := app.renderSite(); err != nil {
+		t.Fatal(err)
+	}
+	// The handler is bound before the config changes, like in LiveServer
+	handler := app.getLivePage
+	oldDist := app.DistDir
+	newDist := filepath.Join(filepath.Dir(srcDir), "public")
+	config := `{"dist": "` + filepath.ToSlash(newDist) + `"}`
+	if err := os.WriteFile(filepath.Join(srcDir, ".squatch"), []byte(config), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := app.rebuild(filepath.Join(srcDir, ".squatch")); err != nil {
+		t.Fatal(err)
+	}
+	os.RemoveAll(oldDist)
+	w := httptest.NewRecorder()
+	handler(w, httptest.NewRequest("GET", "/page", nil))
+	if w.Code != 200 || !strings.Contains(w.Body.String(), "Hello") {
+		t.Errorf("expected the page from the new dist folder, got %d %v", w.Code, w.Body.String())
+	}
+}
+
+func TestGetLivePageInjectsReload(t *testing.T) {
+	srcDir := "src_test"
+	defer cleanup("dist")
+	Build(srcDir)
+	app := App{DistDir: "dist"}
+	req := httptest.NewRequest("GET", "/pages/example", nil)
+	w := httptest.NewRecorder()
+	app.getLivePage(w, req)
+	if !strings.Contains(w.Body.String(), liveReloadPath) {
+		t.Fatalf("expected live reload script in page, got %s", w.Body.String())
+	}
+	req = httptest.NewRequest("GET", "/static/main.css", nil)
+	w = httptest.NewRecorder()
+	app.getLivePage(w, req)
+	if strings.Contains(w.Body.String(), liveReloadPath) {
+		t.Fatalf("expected no live reload script in stylesheet")
+	}
+}
+
+func TestWatchDir(t *testing.T) {
+	srcDir := t.TempDir()
+	for _, dir := range []string{"pages/nested", "static", "dist", ".git", "node_modules"} {
+		if err := os.MkdirAll(filepath.Join(srcDir, dir), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	app := App{SrcDir: srcDir, DistDir: "dist", IgnoreFolders: map[string]bool{"dist": true, "node_modules": true}}
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer watcher.Close()
+	if err := app.watchDir(watcher, srcDir); err != nil {
+		t.Fatal(err)
+	}
+	watched := map[string]bool{}
+	for _, p := range watcher.WatchList() {
+		watched[p] = true
+	}
+	for _, dir := range []string{"", "pages", "pages/nested", "static"} {
+		if !watched[filepath.Join(srcDir, dir)] {
+			t.Errorf("expected %v to be watched", dir)
+		}
+	}
+	for _, dir := range []string{"dist", ".git", "node_modules"} {
+		if watched[filepath.Join(srcDir, dir)] {
+			t.Errorf("expected %v to not be watched", dir)
+		}
+	}
+}
+
+func TestUpdateWatchList(t *testing.T) {
+	srcDir := t.TempDir()
+	app := App{SrcDir: srcDir, DistDir: "dist", IgnoreFolders: map[string]bool{"dist": true}}
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer watcher.Close()
+	if err := app.watchDir(watcher, srcDir); err != nil {
+		t.Fatal(err)
+	}
+
+	// New folders are watched along with anything already inside them
+	created := filepath.Join(srcDir, "posts")
+	if err := os.MkdirAll(filepath.Join(created, "2023"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	app.updateWatchList(watcher, fsnotify.Event{Name: created, Op: fsnotify.Create})
+	if len(watcher.WatchList()) != 3 {
+		t.Fatalf("expected 3 watched folders, got %v", watcher.WatchList())
+	}
+
+	// Removed folders are dropped along with their subfolders
+	app.updateWatchList(watcher, fsnotify.Event{Name: created, Op: fsnotify.Remove})
+	if len(watcher.WatchList()) != 1 || watcher.WatchList()[0] != srcDir {
+		t.Fatalf("expected only %v to be watched, got %v", srcDir, watcher.WatchList())
+	}
+}
+
+func TestRefreshWatchList(t *testing.T) {
+	srcDir := t.TempDir()
+	for _, dir := range []string{"drafts/old", "static"} {
+		if err := os.MkdirAll(filepath.Join(srcDir, dir), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	app := App{SrcDir: srcDir, DistDir: "dist", IgnoreFolders: map[string]bool{"static": true}}
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer watcher.Close()
+	if err := app.watchDir(watcher, srcDir); err != nil {
+		t.Fatal(err)
+	}
+
+	// A new config ignores drafts and no longer ignores static
+	app.IgnoreFolders = map[string]bool{"drafts": true}
+	app.refreshWatchList(watcher)
+	watched := map[string]bool{}
+	for _, p := range watcher.WatchList() {
+		watched[p] = true
+	}
+	for _, dir := range []string{"", "static"} {
+		if !watched[filepath.Join(srcDir, dir)] {
+			t.Errorf("expected %v to be watched", dir)
+		}
+	}
+	for _, dir := range []string{"drafts", "drafts/old"} {
+		if watched[filepath.Join(srcDir, dir)] {
+			t.Errorf("expected %v to not be watched", dir)
+		}
+	}
+}
@@ -0,0 +1,219 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"text/template"
+)
+
+var (
+	// shortcodeTag matches {{< name key="value" >}}, {{< name />}} and
+	// {{< /name >}}
+	shortcodeTag = regexp.MustCompile(`\{\{<\s*(/?)([\w-]+)((?:\s+(?:[\w-]+=)?(?:"[^"]*"|[^\s"/>]+))*)\s*(/?)>\}\}`)
+	// shortcodeArg matches key="value", key=value, "value" and value
+	shortcodeArg = regexp.MustCompile(`(?:([\w-]+)=)?(?:"([^"]*)"|(\S+))`)
+	// escapedShortcode matches {{</* name */>}}, which is written out as
+	// {{< name >}} instead of being expanded
+	escapedShortcode = regexp.MustCompile(`\{\{<(\s*)/\*(.*?)\*/(\s*)>\}\}`)
+	// codeFence matches the opening or closing line of a fenced code block
+	codeFence = regexp.MustCompile("^ {0,3}(`{3,}|~{3,})")
+	// backticks matches the runs of backticks around code spans
+	backticks = regexp.MustCompile("`+")
+)
+
+// Shortcode is the data available to a shortcode template
+type Shortcode struct {
+	Name string
+	// Params are the key="value" arguments and Args the positional ones
+	Params map[string]string
+	Args   []string
+	// Inner is the raw content between the opening and closing tags and
+	// InnerHTML is that content rendered as markdown
+	Inner     string
+	InnerHTML string
+	// Page is the page the shortcode is used in
+	Page Page
+}
+
+// shortcodeName returns the name of the shortcode defined by the file at path
+// and whether the file is a shortcode at all
+func shortcodeName(path string) (string, bool) {
+	base := filepath.Base(path)
+	if filepath.Ext(base) == ".html" && strings.HasPrefix(base, "shortcode_") {
+		name := strings.TrimSuffix(base, ".html")
+		return strings.TrimPrefix(name, "shortcode_"), true
+	}
+	return "", false
+}
+
+// loadShortcode reads the shortcode at path into the app
+func (app *App) loadShortcode(path string) error {
+	shortcodeByte, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	name, _ := shortcodeName(path)
+	app.Shortcodes[name] = string(shortcodeByte)
+	return nil
+}
+
+// parseShortcodeArgs splits the arguments of a shortcode tag into named and
+// positional arguments
+func parseShortcodeArgs(args string) (map[string]string, []string) {
+	params := map[string]string{}
+	positional := []string{}
+	for _, match := range shortcodeArg.FindAllStringSubmatch(args, -1) {
+		value := match[2]
+		if match[3] != "" {
+			value = match[3]
+		}
+		if match[1] != "" {
+			params[match[1]] = value
+		} else {
+			positional = append(positional, value)
+		}
+	}
+	return params, positional
+}
+
+// maskCode returns content with its fenced code blocks and code spans
+// replaced by spaces, so the shortcodes they show are not expanded. Offsets
+// in the masked content are the same as in content.
+func maskCode(content string) string {
+	masked := []byte(content)
+	blank := func(start, end int) {
+		for i := start; i < end; i++ {
+			if masked[i] != '\n' {
+				masked[i] = ' '
+			}
+		}
+	}
+	fence := ""
+	fenceStart := 0
+	textStart := 0
+	offset := 0
+	for _, line := range strings.SplitAfter(content, "\n") {
+		match := codeFence.FindStringSubmatch(line)
+		switch {
+		case fence == "" && match != nil:
+			maskCodeSpans(content[textStart:offset], textStart, blank)
+			fence, fenceStart = match[1], offset
+		case fence != "" && match != nil && match[1][0] == fence[0] && len(match[1]) >= len(fence) &&
+			strings.TrimSpace(line[len(match[0]):]) == "":
+			blank(fenceStart, offset+len(line))
+			fence, textStart = "", offset+len(line)
+		}
+		offset += len(line)
+	}
+	// Code blocks without a closing fence run to the end of the content
+	if fence != "" {
+		blank(fenceStart, len(content))
+	} else {
+		maskCodeSpans(content[textStart:], textStart, blank)
+	}
+	return string(masked)
+}
+
+// maskCodeSpans calls blank with the offsets of every code span in text,
+// which starts at start in the content
+func maskCodeSpans(text string, start int, blank func(int, int)) {
+	runs := backticks.FindAllStringIndex(text, -1)
+	for i := 0; i < len(runs); i++ {
+		open := runs[i]
+		for j := i + 1; j < len(runs); j++ {
+			if runs[j][1]-runs[j][0] == open[1]-open[0] {
+				blank(start+open[0], start+runs[j][1])
+				i = j
+				break
+			}
+		}
+	}
+}
+
+// closingShortcode finds the tag closing the shortcode name in the masked
+// content and returns its start and end. Shortcodes of the same name can be
+// nested inside it.
+func closingShortcode(masked string, name string) (int, int, bool) {
+	depth := 0
+	for _, loc := range shortcodeTag.FindAllStringSubmatchIndex(masked, -1) {
+		if masked[loc[4]:loc[5]] != name || masked[loc[8]:loc[9]] == "/" {
+			continue
+		}
+		if masked[loc[2]:loc[3]] != "/" {
+			depth++
+		} else if depth > 0 {
+			depth--
+		} else {
+			return loc[0], loc[1], true
+		}
+	}
+	return 0, 0, false
+}
+
+// expandShortcodes replaces the shortcodes in the markdown content of page
+// with their rendered templates. Shortcodes without a closing tag have no
+// inner content. Shortcodes in code and escaped shortcodes are left as they
+// are.
+func (app App) expandShortcodes(content string, page Page) (string, error) {
+	var out strings.Builder
+	masked := maskCode(content)
+	for {
+		loc := shortcodeTag.FindStringSubmatchIndex(masked)
+		if escaped := escapedShortcode.FindStringSubmatchIndex(masked); escaped != nil && (loc == nil || escaped[0] < loc[0]) {
+			out.WriteString(content[:escaped[0]])
+			out.WriteString("{{<" + content[escaped[2]:escaped[3]] + content[escaped[4]:escaped[5]] + content[escaped[6]:escaped[7]] + ">}}")
+			content, masked = content[escaped[1]:], masked[escaped[1]:]
+			continue
+		}
+		if loc == nil {
+			out.WriteString(content)
+			return out.String(), nil
+		}
+		out.WriteString(content[:loc[0]])
+		name := content[loc[4]:loc[5]]
+		if content[loc[2]:loc[3]] == "/" {
+			return "", fmt.Errorf("closing shortcode %v has no opening tag", name)
+		}
+		shortcode := Shortcode{Name: name, Page: page}
+		shortcode.Params, shortcode.Args = parseShortcodeArgs(content[loc[6]:loc[7]])
+		selfClosing := content[loc[8]:loc[9]] == "/"
+		content, masked = content[loc[1]:], masked[loc[1]:]
+		if !selfClosing {
+			if start, end, ok := closingShortcode(masked, name); ok {
+				expanded, err := app.expandShortcodes(content[:start], page)
+				if err != nil {
+					return "", err
+				}
+				shortcode.Inner = strings.TrimPrefix(expanded, "\n")
+				shortcode.InnerHTML = string(app.markdownToHTML([]byte(shortcode.Inner)))
+				content, masked = content[end:], masked[end:]
+			}
+		}
+		rendered, err := app.renderShortcode(shortcode)
+		if err != nil {
+			return "", err
+		}
+		out.WriteString(rendered)
+	}
+}
+
+// renderShortcode executes the template of a shortcode
+func (app App) renderShortcode(shortcode Shortcode) (string, error) {
+	source, ok := app.Shortcodes[shortcode.Name]
+	if !ok {
+		return "", fmt.Errorf("unknown shortcode %v", shortcode.Name)
+	}
+	t, err := template.New(shortcode.Name).Funcs(app.templateFuncs()).Parse(source)
+	if err != nil {
+		return "", fmt.Errorf("error parsing shortcode %v: %w", shortcode.Name, err)
+	}
+	var rendered bytes.Buffer
+	if err := t.Execute(&rendered, shortcode); err != nil {
+		return "", fmt.Errorf("error executing shortcode %v: %w", shortcode.Name, err)
+	}
+	return rendered.String(), nil
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestShortcodeName(t *testing.T) {
+	tests := []struct {
+		path string
+		name string
+		ok   bool
+	}{
+		{"src/shortcode_note.html", "note", true},
+		{"src/docs/shortcode_youtube-embed.html", "youtube-embed", true},
+		{"src/layout_note.html", "", false},
+		{"src/shortcode_note.md", "", false},
+	}
+	for _, test := range tests {
+		name, ok := shortcodeName(test.path)
+		if name != test.name || ok != test.ok {
+			t.Errorf("shortcodeName(%q) = %q, %v, expected %q, %v", test.path, name, ok, test.name, test.ok)
+		}
+	}
+}
+
+func TestParseShortcodeArgs(t *testing.T) {
+	params, args := parseShortcodeArgs(` abc type="warning note" size=large "two words"`)
+	if !reflect.DeepEqual(params, map[string]string{"type": "warning note", "size": "large"}) {
+		t.Errorf("unexpected params %v", params)
+	}
+	if !reflect.DeepEqual(args, []string{"abc", "two words"}) {
+		t.Errorf("unexpected args %v", args)
+	}
+}
+
+func TestExpandShortcodes(t *testing.T) {
+	app := App{Shortcodes: map[string]string{
+		"note":  `<div class="{{.Params.type}}">{{.Inner}}</div>`,
+		"br":    `<br>`,
+		"title": `{{.Page.Title}}`,
+	}}
+	page := Page{Title: "Page"}
+	tests := []struct {
+		name     string
+		content  string
+		expected string
+	}{
+		{"no shortcodes", "Just {{ text }}", "Just {{ text }}"},
+		{"self closing", "a {{< br />}} b", "a <br> b"},
+		{"no closing tag", "a {{<br>}} b", "a <br> b"},
+		{"inner content", `{{< note type="tip" >}}Hi{{< /note >}}`, `<div class="tip">Hi</div>`},
+		{"nested", `{{< note type="a" >}}{{< note type="b" >}}x{{< /note >}}{{< br />}}{{< /note >}}`, `<div class="a"><div class="b">x</div><br></div>`},
+		{"page data", "{{< title >}}", "Page"},
+		{"code span", "use `{{< missing >}}` or ``{{< missing />}}`` {{< br />}}", "use `{{< missing >}}` or ``{{< missing />}}`` <br>"},
+		{"fenced code", "```\n{{< missing >}}\n```\n{{< br />}}", "```\n{{< missing >}}\n```\n<br>"},
+		{"nested fences", "````md\n```\n{{< missing >}}\n```\n````\n{{< br />}}", "````md\n```\n{{< missing >}}\n```\n````\n<br>"},
+		{"unclosed fence", "~~~\n{{< missing >}}", "~~~\n{{< missing >}}"},
+		{"escaped", `{{</* note type="tip" */>}} {{< br />}}`, `{{< note type="tip" >}} <br>`},
+		{"escaped self closing", `{{</* br /*/>}}`, `{{< br />}}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			output, err := app.expandShortcodes(tt.content, page)
+			if err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+			if output != tt.expected {
+				t.Errorf("Expected: %s, got: %s", tt.expected, output)
+			}
+		})
+	}
+	for _, content := range []string{"{{< missing >}}", "{{< /note >}}"} {
+		if _, err := app.expandShortcodes(content, page); err == nil {
+			t.Errorf("expected an error expanding %q", content)
+		}
+	}
+}
+
+func TestRenderShortcodes(t *testing.T) {
+	srcTest := "src_test"
+	defer cleanup("dist")
+	Build(srcTest)
+	data, err := os.ReadFile(filepath.Join("dist", "pages", "shortcodes.html"))
+	if err != nil {
+		t.Fatalf("expected shortcodes.html to exist, got %v", err)
+	}
+	body := string(data)
+	if !strings.Contains(body, `<div class="note note-warning">`) || !strings.Contains(body, "<strong>gap</strong>") {
+		t.Errorf("expected the note shortcode with its inner markdown, got %v", body)
+	}
+	if !strings.Contains(body, `src="https://www.youtube.com/embed/dQw4w9WgXcQ" title="Shortcodes"`) {
+		t.Errorf("expected the youtube shortcode, got %v", body)
+	}
+	if _, err := os.Stat(filepath.Join("dist", "shortcode_note.html")); err == nil {
+		t.Errorf("expected shortcodes not to be copied to dist")
+	}
+}
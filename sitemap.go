@@ -0,0 +1,99 @@
+package main
+
+import (
+	"encoding/xml"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+type urlSet struct {
+	XMLName xml.Name     `xml:"http://www.sitemaps.org/schemas/sitemap/0.9 urlset"`
+	URLs    []sitemapURL `xml:"url"`
+}
+
+type sitemapURL struct {
+	Loc     string `xml:"loc"`
+	LastMod string `xml:"lastmod,omitempty"`
+}
+
+// pageLastMod returns when a page last changed from its lastmod or date param,
+// falling back to the modification time of its markdown file
+func pageLastMod(page Page) time.Time {
+	if lastmod := paramDate(page.Params["lastmod"]); !lastmod.IsZero() {
+		return lastmod
+	}
+	if !page.Date.IsZero() {
+		return page.Date
+	}
+	if info, err := os.Stat(page.Filepath); err == nil {
+		return info.ModTime()
+	}
+	return time.Time{}
+}
+
+// inSitemap reports whether a page is listed in the sitemap. Pages can opt out
+// with a sitemap param set to false.
+func (app App) inSitemap(page Page) bool {
+	if excluded, ok := page.Params["sitemap"].(bool); ok && !excluded {
+		return false
+	}
+	// Pages without a layout are not rendered
+	_, ok := app.pageLayout(page)
+	return ok
+}
+
+// renderSitemap writes sitemap.xml listing every rendered page when the site
+// has a baseURL
+func (app App) renderSitemap() error {
+	if app.Config.BaseURL == "" {
+		return nil
+	}
+	sitemap := urlSet{}
+	for _, page := range app.Pages {
+		if !app.inSitemap(page) {
+			continue
+		}
+		entry := sitemapURL{Loc: app.absURL(page.URL)}
+		if lastmod := pageLastMod(page); !lastmod.IsZero() {
+			entry.LastMod = lastmod.Format(time.RFC3339)
+		}
+		sitemap.URLs = append(sitemap.URLs, entry)
+	}
+	return app.writeXML("sitemap.xml", sitemap)
+}
+
+// robotsTxt returns the contents of robots.txt from the robots config
+func (app App) robotsTxt() string {
+	config := app.Config.Robots
+	if config.Content != "" {
+		return config.Content
+	}
+	var robots strings.Builder
+	robots.WriteString("User-agent: *\n")
+	if len(config.Disallow) == 0 {
+		robots.WriteString("Allow: /\n")
+	}
+	for _, path := range config.Disallow {
+		fmt.Fprintf(&robots, "Disallow: %v\n", path)
+	}
+	if app.Config.BaseURL != "" {
+		fmt.Fprintf(&robots, "\nSitemap: %v\n", app.absURL("/sitemap.xml"))
+	}
+	return robots.String()
+}
+
+// renderRobots writes robots.txt when the site has a baseURL, unless the
+// source directory has its own robots.txt
+func (app App) renderRobots() error {
+	if app.Config.BaseURL == "" {
+		return nil
+	}
+	if _, err := os.Stat(filepath.Join(app.SrcDir, "robots.txt")); err == nil {
+		return nil
+	}
+	fp := filepath.Join(app.DistDir, "robots.txt")
+	return app.recordError(fp, os.WriteFile(fp, []byte(app.robotsTxt()), 0644))
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"encoding/xml"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPageLastMod(t *testing.T) {
+	date := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	lastmod := time.Date(2023, 2, 1, 0, 0, 0, 0, time.UTC)
+	page := Page{Date: date, Params: map[string]interface{}{"lastmod": "2023-02-01"}}
+	if got := pageLastMod(page); !got.Equal(lastmod) {
+		t.Errorf("expected the lastmod param, got %v", got)
+	}
+	page.Params = map[string]interface{}{}
+	if got := pageLastMod(page); !got.Equal(date) {
+		t.Errorf("expected the page date, got %v", got)
+	}
+	page = Page{Filepath: filepath.Join("src_test", "index.md"), Params: map[string]interface{}{}}
+	info, _ := os.Stat(page.Filepath)
+	if got := pageLastMod(page); !got.Equal(info.ModTime()) {
+		t.Errorf("expected the file modification time, got %v", got)
+	}
+}
+
+func TestRenderSitemap(t *testing.T) {
+	srcTest := "src_test"
+	defer cleanup("dist")
+	Build(srcTest)
+	data, err := os.ReadFile(filepath.Join("dist", "sitemap.xml"))
+	if err != nil {
+		t.Fatalf("expected sitemap.xml to exist, got %v", err)
+	}
+	var sitemap urlSet
+	if err := xml.Unmarshal(data, &sitemap); err != nil {
+		t.Fatalf("expected sitemap.xml to be valid xml, got %v", err)
+	}
+	urls := map[string]string{}
+	for _, url := range sitemap.URLs {
+		urls[url.Loc] = url.LastMod
+	}
+	if _, ok := urls["https://example.com/docs/"]; !ok {
+		t.Errorf("expected the index page in the sitemap, got %v", urls)
+	}
+	if lastmod := urls["https://example.com/docs/pages/frontmatter.html"]; lastmod != "2023-04-01T00:00:00Z" {
+		t.Errorf("expected the lastmod param, got %v", lastmod)
+	}
+	if _, ok := urls["https://example.com/docs/pages/toml.html"]; ok {
+		t.Errorf("expected pages with sitemap false to be excluded")
+	}
+}
+
+func TestRenderRobots(t *testing.T) {
+	srcTest := "src_test"
+	defer cleanup("dist")
+	Build(srcTest)
+	data, err := os.ReadFile(filepath.Join("dist", "robots.txt"))
+	if err != nil {
+		t.Fatalf("expected robots.txt to exist, got %v", err)
+	}
+	expected := "User-agent: *\nDisallow: /drafts/\n\nSitemap: https://example.com/docs/sitemap.xml\n"
+	if string(data) != expected {
+		t.Errorf("expected %q, got %q", expected, string(data))
+	}
+}
+
+func TestRobotsTxt(t *testing.T) {
+	app := App{Config: SquatchConfig{BaseURL: "https://example.com"}}
+	if robots := app.robotsTxt(); !strings.HasPrefix(robots, "User-agent: *\nAllow: /\n") {
+		t.Errorf("expected everything to be allowed, got %q", robots)
+	}
+	app.Config.Robots.Content = "User-agent: *\nDisallow: /\n"
+	if robots := app.robotsTxt(); robots != app.Config.Robots.Content {
+		t.Errorf("expected the configured content, got %q", robots)
+	}
+}
+
+func TestRenderSitemapNoBaseURL(t *testing.T) {
+	app := App{DistDir: t.TempDir()}
+	if err := app.renderSitemap(); err != nil {
+		t.Fatal(err)
+	}
+	if err := app.renderRobots(); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"sitemap.xml", "robots.txt"} {
+		if _, err := os.Stat(filepath.Join(app.DistDir, name)); err == nil {
+			t.Errorf("expected no %v without a baseURL", name)
+		}
+	}
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// taxonomies are the page params that group pages into term listings
+var taxonomies = []string{"tags", "categories"}
+
+// Layouts used for the generated taxonomy pages
+const (
+	taxonomyLayout = "taxonomy"
+	termLayout     = "term"
+)
+
+// Term is one value of a taxonomy, like the go tag, with the pages using it
+type Term struct {
+	Name  string
+	Slug  string
+	URL   string
+	Pages []Page
+}
+
+// slugify turns s into a lowercase url path segment, keeping letters and
+// numbers and replacing everything else with single dashes
+func slugify(s string) string {
+	var slug strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(strings.TrimSpace(s)) {
+		if unicode.IsLetter(r) || unicode.IsNumber(r) {
+			slug.WriteRune(r)
+			dash = false
+		} else if !dash && slug.Len() > 0 {
+			slug.WriteRune('-')
+			dash = true
+		}
+	}
+	return strings.TrimSuffix(slug.String(), "-")
+}
+
+// pageTerms returns the terms a page lists for a taxonomy, either as a list or
+// a single value
+func pageTerms(page Page, taxonomy string) []string {
+	switch value := page.Params[taxonomy].(type) {
+	case []interface{}:
+		terms := make([]string, 0, len(value))
+		for _, term := range value {
+			terms = append(terms, fmt.Sprint(term))
+		}
+		return terms
+	case []string:
+		return value
+	case string:
+		return []string{value}
+	}
+	return nil
+}
+
+// indexTaxonomies groups the pages by the terms of every taxonomy. Terms are
+// sorted by name and matched by slug so "Go" and "go" are the same term, and
+// a page listing a term twice is only added to it once.
+func (app *App) indexTaxonomies() {
+	app.Taxonomies = map[string][]Term{}
+	for _, taxonomy := range taxonomies {
+		terms := []Term{}
+		bySlug := map[string]int{}
+		for _, page := range app.Pages {
+			listed := map[string]bool{}
+			for _, name := range pageTerms(page, taxonomy) {
+				slug := slugify(name)
+				if slug == "" || listed[slug] {
+					continue
+				}
+				listed[slug] = true
+				i, ok := bySlug[slug]
+				if !ok {
+					i = len(terms)
+					bySlug[slug] = i
+					terms = append(terms, Term{Name: name, Slug: slug, URL: "/" + taxonomy + "/" + slug + "/"})
+				}
+				terms[i].Pages = append(terms[i].Pages, page)
+			}
+		}
+		sort.SliceStable(terms, func(i, j int) bool {
+			return strings.ToLower(terms[i].Name) < strings.ToLower(terms[j].Name)
+		})
+		app.Taxonomies[taxonomy] = terms
+	}
+}
+
+// renderTaxonomies renders /<taxonomy>/ with the taxonomy layout and
+// /<taxonomy>/<term>/ with the term layout. The layouts are looked up from the
+// taxonomy's folder like the layouts of pages. Sites without those layouts
+// don't get taxonomy pages.
+func (app App) renderTaxonomies() error {
+	for _, taxonomy := range taxonomies {
+		terms := app.Taxonomies[taxonomy]
+		if _, ok := app.resolveLayout(taxonomy, taxonomyLayout); ok {
+			page := Page{
+				Title:   taxonomy,
+				Layout:  taxonomyLayout,
+				Section: taxonomy,
+				URL:     "/" + taxonomy + "/",
+				Params:  map[string]interface{}{"taxonomy": taxonomy},
+				Terms:   terms,
+			}
+			err := app.renderPageTo(page, filepath.Join(app.DistDir, taxonomy, "index.html"))
+			if err := app.recordError(page.URL, err); err != nil {
+				return err
+			}
+		}
+		if _, ok := app.resolveLayout(taxonomy, termLayout); !ok {
+			continue
+		}
+		err := forEach(len(terms), func(i int) error {
+			page := Page{
+				Title:   terms[i].Name,
+				Layout:  termLayout,
+				Section: taxonomy,
+				URL:     terms[i].URL,
+				Params:  map[string]interface{}{"taxonomy": taxonomy},
+				Term:    &terms[i],
+			}
+			err := app.renderPageTo(page, filepath.Join(app.DistDir, taxonomy, terms[i].Slug, "index.html"))
+			return app.recordError(page.URL, err)
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSlugify(t *testing.T) {
+	tests := map[string]string{
+		"Go":                "go",
+		"Static Sites":      "static-sites",
+		"  C++ & Go!  ":     "c-go",
+		"Release v1.0":      "release-v1-0",
+		"Ünïcode Tâg":       "ünïcode-tâg",
+		"already-slugged":   "already-slugged",
+		"multiple   spaces": "multiple-spaces",
+	}
+	for s, expected := range tests {
+		if slug := slugify(s); slug != expected {
+			t.Errorf("expected slug of %q to be %q, got %q", s, expected, slug)
+		}
+	}
+}
+
+func TestPageTerms(t *testing.T) {
+	page := Page{Params: map[string]interface{}{
+		"tags":       []interface{}{"go", "markdown"},
+		"categories": "Releases",
+	}}
+	if terms := pageTerms(page, "tags"); len(terms) != 2 || terms[1] != "markdown" {
+		t.Errorf("expected [go markdown], got %v", terms)
+	}
+	if terms := pageTerms(page, "categories"); len(terms) != 1 || terms[0] != "Releases" {
+		t.Errorf("expected [Releases], got %v", terms)
+	}
+	if terms := pageTerms(page, "missing"); terms != nil {
+		t.Errorf("expected no terms, got %v", terms)
+	}
+}
+
+func TestIndexTaxonomies(t *testing.T) {
+	app := App{Pages: []Page{
+		{Title: "One", Params: map[string]interface{}{"tags": []interface{}{"Go", "markdown"}}},
+		{Title: "Two", Params: map[string]interface{}{"tags": []interface{}{"go", "go", "Go"}, "categories": "Releases"}},
+		{Title: "Three", Params: map[string]interface{}{}},
+	}}
+	app.indexTaxonomies()
+	tags := app.Taxonomies["tags"]
+	if len(tags) != 2 {
+		t.Fatalf("expected 2 tags, got %v", tags)
+	}
+	if tags[0].Name != "Go" || tags[0].Slug != "go" || tags[0].URL != "/tags/go/" || len(tags[0].Pages) != 2 {
+		t.Errorf("expected Go and go to be merged once per page, got %v", tags[0])
+	}
+	if tags[1].Name != "markdown" || len(tags[1].Pages) != 1 {
+		t.Errorf("expected markdown to have one page, got %v", tags[1])
+	}
+	categories := app.Taxonomies["categories"]
+	if len(categories) != 1 || categories[0].URL != "/categories/releases/" {
+		t.Errorf("expected one releases category, got %v", categories)
+	}
+}
+
+func TestRenderTaxonomies(t *testing.T) {
+	srcTest := "src_test"
+	defer cleanup("dist")
+	Build(srcTest)
+	data, err := os.ReadFile(filepath.Join("dist", "tags", "index.html"))
+	if err != nil {
+		t.Fatalf("expected tags/index.html to exist, got %v", err)
+	}
+	if !strings.Contains(string(data), `<li><a href="/tags/go/">Go</a> (4)</li>`) {
+		t.Errorf("expected the go tag with four pages, got %s", string(data))
+	}
+	data, err = os.ReadFile(filepath.Join("dist", "tags", "markdown", "index.html"))
+	if err != nil {
+		t.Fatalf("expected tags/markdown/index.html to exist, got %v", err)
+	}
+	if !strings.Contains(string(data), `<a href="/blog/third.html">Third Post</a>`) {
+		t.Errorf("expected the term page to list its pages, got %s", string(data))
+	}
+	if _, err := os.Stat(filepath.Join("dist", "categories", "releases", "index.html")); err != nil {
+		t.Errorf("expected categories/releases/index.html to exist, got %v", err)
+	}
+}
+
+func TestRenderTaxonomiesFolderLayouts(t *testing.T) {
+	srcDir := writeSite(t, "", map[string]string{
+		"layout.html":               "{{.Body}}",
+		"layout_page.html":          "<main>{{.Body}}</main>",
+		"tags/layout_taxonomy.html": `{{range .Terms}}<a href="{{.URL}}">{{.Name}}</a>{{end}}`,
+		"tags/layout_term.html":     `<h1>{{.Term.Name}}</h1>`,
+		"post.md":                   "---\ntitle: Post\nlayout: page\ntags: [Go]\n---\n",
+	})
+	if err := Build(srcDir); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	dist := filepath.Join(filepath.Dir(srcDir), "dist")
+	data, err := os.ReadFile(filepath.Join(dist, "tags", "index.html"))
+	if err != nil {
+		t.Fatalf("expected tags/index.html to exist, got %v", err)
+	}
+	if !strings.Contains(string(data), `<a href="/tags/go/">Go</a>`) {
+		t.Errorf("expected the taxonomy layout from the tags folder, got %s", string(data))
+	}
+	data, err = os.ReadFile(filepath.Join(dist, "tags", "go", "index.html"))
+	if err != nil {
+		t.Fatalf("expected tags/go/index.html to exist, got %v", err)
+	}
+	if !strings.Contains(string(data), "<h1>Go</h1>") {
+		t.Errorf("expected the term layout from the tags folder, got %s", string(data))
+	}
+	if _, err := os.Stat(filepath.Join(dist, "categories", "index.html")); err == nil {
+		t.Errorf("expected the tags layouts not to apply to categories")
+	}
+}
@@ -0,0 +1,77 @@
+package main
+
+import "sync"
+
+// templateCache holds the template sets parsed by layoutTemplates so every
+// page using the same site template and layout shares one parsed set
+type templateCache struct {
+	mu   sync.Mutex
+	sets map[templateKey]*cachedTemplate
+}
+
+// templateKey is the site template file and layout key a set was parsed from
+type templateKey struct {
+	site   string
+	layout string
+}
+
+type cachedTemplate struct {
+	once sync.Once
+	t    pageTemplate
+	root string
+	err  error
+}
+
+func newTemplateCache() *templateCache {
+	return &templateCache{sets: map[templateKey]*cachedTemplate{}}
+}
+
+// get returns the set cached at key, calling parse to parse it the first
+// time. Pages rendered at the same time wait for a single parse.
+func (c *templateCache) get(key templateKey, parse func() (pageTemplate, string, error)) (pageTemplate, string, error) {
+	c.mu.Lock()
+	set, ok := c.sets[key]
+	if !ok {
+		set = &cachedTemplate{}
+		c.sets[key] = set
+	}
+	c.mu.Unlock()
+	set.once.Do(func() {
+		set.t, set.root, set.err = parse()
+	})
+	return set.t, set.root, set.err
+}
+
+// drop removes the sets whose layout is matched by stale
+func (c *templateCache) drop(stale func(layout string) bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for key := range c.sets {
+		if stale(key.layout) {
+			delete(c.sets, key)
+		}
+	}
+}
+
+// reset removes every set, for changes like partials that every set uses
+func (c *templateCache) reset() {
+	c.drop(func(string) bool { return true })
+}
+
+// invalidateLayout drops the cached sets that use the layout at key, directly
+// or through a layout extending it
+func (app App) invalidateLayout(key string) {
+	if app.TemplateCache == nil {
+		return
+	}
+	app.TemplateCache.drop(func(layout string) bool {
+		return app.usesLayout(layout, key)
+	})
+}
+
+// invalidateTemplates drops every cached set
+func (app App) invalidateTemplates() {
+	if app.TemplateCache != nil {
+		app.TemplateCache.reset()
+	}
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"fmt"
+	"path"
+	"testing"
+)
+
+func TestTemplateCache(t *testing.T) {
+	cache := newTemplateCache()
+	parses := 0
+	parse := func() (pageTemplate, string, error) {
+		parses++
+		return nil, "layout_post.html", nil
+	}
+	post := templateKey{site: "layout.html", layout: "post"}
+	docs := templateKey{site: "docs/layout.html", layout: "post"}
+	for i := 0; i < 3; i++ {
+		cache.get(post, parse)
+		cache.get(docs, parse)
+	}
+	if parses != 2 {
+		t.Errorf("expected each set to be parsed once, got %d parses", parses)
+	}
+	cache.drop(func(layout string) bool { return layout == "post" })
+	cache.get(post, parse)
+	if parses != 3 {
+		t.Errorf("expected a dropped set to be parsed again, got %d parses", parses)
+	}
+	cache.reset()
+	if len(cache.sets) != 0 {
+		t.Errorf("expected reset to drop every set, got %v", cache.sets)
+	}
+}
+
+func TestInvalidateLayout(t *testing.T) {
+	app := App{
+		Layouts: map[string]string{
+			"article": "<article>{{.Body}}</article>",
+			"post":    `{{/* extends "article" */}}`,
+			"page":    "{{.Body}}",
+		},
+		TemplateCache: newTemplateCache(),
+	}
+	for layout := range app.Layouts {
+		app.TemplateCache.get(templateKey{site: "layout.html", layout: layout}, func() (pageTemplate, string, error) {
+			return nil, "", nil
+		})
+	}
+	app.invalidateLayout("article")
+	for layout, cached := range map[string]bool{"article": false, "post": false, "page": true} {
+		if _, ok := app.TemplateCache.sets[templateKey{site: "layout.html", layout: layout}]; ok != cached {
+			t.Errorf("expected %v to be cached: %v, got %v", layout, cached, ok)
+		}
+	}
+}
+
+// BenchmarkRenderPages renders a site of 5000 pages using layouts that extend
+// each other and a partial, with and without the template cache
+func BenchmarkRenderPages(b *testing.B) {
+	files := map[string]string{
+		"layout.html":         `<html><head><title>{{.Title}}</title></head><body>{{template "partial_nav.html" .}}{{.Body}}</body></html>`,
+		"partial_nav.html":    `<nav><a href="/">{{.Site.Title}}</a> / {{.Title}}</nav>`,
+		"layout_article.html": `<article>{{block "header" .}}<h1>{{.Title}}</h1>{{end}}{{.Body}}</article>`,
+		"layout_default.html": `{{/* extends "article" */}}{{define "header"}}<h1 class="post">{{.Title}}</h1>{{end}}`,
+	}
+	for i := 0; i < 5000; i++ {
+		files[path.Join(fmt.Sprintf("section%d", i%50), fmt.Sprintf("page%d.md", i))] = fmt.Sprintf("---\ntitle: Page %d\n---\n# Page %d\n\nSome *text* for page %d.\n", i, i, i)
+	}
+	srcDir := writeSite(b, "", files)
+	app, err := InitApp(srcDir)
+	if err != nil {
+		b.Fatal(err)
+	}
+	cache := app.TemplateCache
+	for _, cached := range []bool{false, true} {
+		b.Run(fmt.Sprintf("cached=%v", cached), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				app.TemplateCache = nil
+				if cached {
+					cache.reset()
+					app.TemplateCache = cache
+				}
+				if err := app.renderPages(); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,212 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	htmltemplate "html/template"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	texttemplate "text/template"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	// textTemplates renders layouts with text/template, inserting every value
+	// as is
+	textTemplates = "text"
+	// htmlTemplates renders layouts with html/template, escaping every value
+	// except the page body. This is the default.
+	htmlTemplates = "html"
+)
+
+// pageTemplate is a parsed set of text or html templates
+type pageTemplate interface {
+	Execute(w io.Writer, data interface{}) error
+	ExecuteTemplate(w io.Writer, name string, data interface{}) error
+}
+
+// templateSource is a template to parse into a set
+type templateSource struct {
+	name string
+	text string
+}
+
+// checkTemplateMode returns an error for unknown template modes
+func checkTemplateMode(mode string) error {
+	if mode != "" && mode != textTemplates && mode != htmlTemplates {
+		return fmt.Errorf("unknown template mode %q", mode)
+	}
+	return nil
+}
+
+// parseTemplates parses sources into a single set using the configured
+// template mode. The first source is the template the set executes.
+func (app App) parseTemplates(sources []templateSource) (pageTemplate, error) {
+	funcs := app.templateFuncs()
+	if app.Config.Templates != textTemplates {
+		t := htmltemplate.New(sources[0].name).Funcs(funcs)
+		for i, source := range sources {
+			parse := t
+			if i > 0 {
+				parse = t.New(source.name)
+			}
+			if _, err := parse.Parse(source.text); err != nil {
+				return nil, err
+			}
+		}
+		return t, nil
+	}
+	t := texttemplate.New(sources[0].name).Funcs(funcs)
+	for i, source := range sources {
+		parse := t
+		if i > 0 {
+			parse = t.New(source.name)
+		}
+		if _, err := parse.Parse(source.text); err != nil {
+			return nil, err
+		}
+	}
+	return t, nil
+}
+
+// templateFuncs returns the functions available to layouts
+func (app App) templateFuncs() map[string]interface{} {
+	return map[string]interface{}{
+		"dateFormat":  dateFormat,
+		"markdownify": app.markdownify,
+		"slugify":     slugify,
+		"truncate":    truncate,
+		"relURL":      app.relURL,
+		"absURL":      app.absURL,
+		"where":       where,
+		"sortBy":      sortBy,
+		"readFile":    app.readFile,
+		"jsonify":     jsonify,
+	}
+}
+
+// dateFormat formats a date, or a date string in any of the supported date
+// formats, with a Go time layout
+func dateFormat(layout string, date interface{}) string {
+	t := paramDate(date)
+	if t.IsZero() {
+		return ""
+	}
+	return t.Format(layout)
+}
+
+// markdownify renders markdown to html with the site's theme
+func (app App) markdownify(md string) htmltemplate.HTML {
+	return htmltemplate.HTML(app.markdownToHTML([]byte(md)))
+}
+
+// truncate shortens s to length characters, ending it with an ellipsis
+func truncate(length int, s string) string {
+	if utf8.RuneCountInString(s) <= length {
+		return s
+	}
+	runes := []rune(s)
+	return strings.TrimSpace(string(runes[:length])) + "…"
+}
+
+// relURL returns the url of a path from the site root, including the path of
+// the base url for sites served from a folder
+func (app App) relURL(p string) string {
+	base := "/"
+	if i := strings.Index(app.Config.BaseURL, "://"); i >= 0 {
+		if j := strings.Index(app.Config.BaseURL[i+3:], "/"); j >= 0 {
+			base = app.Config.BaseURL[i+3+j:]
+		}
+	}
+	rel := path.Join(base, p)
+	if strings.HasSuffix(p, "/") && !strings.HasSuffix(rel, "/") {
+		rel += "/"
+	}
+	return rel
+}
+
+// pageField returns the value of a page field like Title, or of a param like
+// Params.author
+func pageField(page Page, key string) interface{} {
+	if strings.HasPrefix(key, "Params.") {
+		return page.Params[strings.TrimPrefix(key, "Params.")]
+	}
+	field := reflect.ValueOf(page).FieldByName(key)
+	if !field.IsValid() {
+		return nil
+	}
+	return field.Interface()
+}
+
+// where returns the pages whose field or param key equals value
+func where(pages []Page, key string, value interface{}) []Page {
+	matches := []Page{}
+	for _, page := range pages {
+		if fmt.Sprint(pageField(page, key)) == fmt.Sprint(value) {
+			matches = append(matches, page)
+		}
+	}
+	return matches
+}
+
+// sortBy returns the pages sorted by a field or param, ascending unless the
+// order is "desc"
+func sortBy(pages []Page, key string, order ...string) []Page {
+	sorted := make([]Page, len(pages))
+	copy(sorted, pages)
+	desc := len(order) > 0 && order[0] == "desc"
+	sort.SliceStable(sorted, func(i, j int) bool {
+		a, b := pageField(sorted[i], key), pageField(sorted[j], key)
+		if desc {
+			return lessValue(b, a)
+		}
+		return lessValue(a, b)
+	})
+	return sorted
+}
+
+// lessValue compares dates and numbers by value and anything else as text
+func lessValue(a interface{}, b interface{}) bool {
+	if at, ok := a.(time.Time); ok {
+		if bt, ok := b.(time.Time); ok {
+			return at.Before(bt)
+		}
+	}
+	av, bv := reflect.ValueOf(a), reflect.ValueOf(b)
+	if av.IsValid() && bv.IsValid() && av.CanFloat() && bv.CanFloat() {
+		return av.Float() < bv.Float()
+	}
+	if av.IsValid() && bv.IsValid() && av.CanInt() && bv.CanInt() {
+		return av.Int() < bv.Int()
+	}
+	return fmt.Sprint(a) < fmt.Sprint(b)
+}
+
+// readFile returns the contents of a file in the source directory
+func (app App) readFile(name string) (string, error) {
+	fp := filepath.Join(app.SrcDir, filepath.FromSlash(name))
+	rel, err := filepath.Rel(app.SrcDir, fp)
+	if err != nil || strings.HasPrefix(rel, "..") {
+		return "", fmt.Errorf("readFile: %v is outside the source directory", name)
+	}
+	data, err := os.ReadFile(fp)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// jsonify encodes v as json for use in scripts
+func jsonify(v interface{}) (htmltemplate.JS, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+	return htmltemplate.JS(data), nil
+}
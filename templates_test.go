@@ -0,0 +1,180 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDateFormat(t *testing.T) {
+	date := time.Date(2023, 2, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		date     interface{}
+		expected string
+	}{
+		{date, "Feb 1, 2023"},
+		{"2023-02-01", "Feb 1, 2023"},
+		{"not a date", ""},
+		{nil, ""},
+	}
+	for _, test := range tests {
+		if got := dateFormat("Jan 2, 2006", test.date); got != test.expected {
+			t.Errorf("dateFormat(%v) = %q, expected %q", test.date, got, test.expected)
+		}
+	}
+}
+
+func TestTruncate(t *testing.T) {
+	tests := []struct {
+		length   int
+		s        string
+		expected string
+	}{
+		{10, "short", "short"},
+		{5, "hello world", "hello…"},
+		{6, "hello world", "hello…"},
+		{3, "héllo", "hél…"},
+	}
+	for _, test := range tests {
+		if got := truncate(test.length, test.s); got != test.expected {
+			t.Errorf("truncate(%v, %q) = %q, expected %q", test.length, test.s, got, test.expected)
+		}
+	}
+}
+
+func TestRelURL(t *testing.T) {
+	tests := []struct {
+		baseURL  string
+		path     string
+		expected string
+	}{
+		{"", "/blog/", "/blog/"},
+		{"https://example.com", "static/main.css", "/static/main.css"},
+		{"https://example.com/docs", "/static/main.css", "/docs/static/main.css"},
+		{"https://example.com/docs/", "blog/", "/docs/blog/"},
+	}
+	for _, test := range tests {
+		app := App{Config: SquatchConfig{BaseURL: test.baseURL}}
+		if got := app.relURL(test.path); got != test.expected {
+			t.Errorf("relURL(%q) with base %q = %q, expected %q", test.path, test.baseURL, got, test.expected)
+		}
+	}
+}
+
+func TestWhereAndSortBy(t *testing.T) {
+	pages := []Page{
+		{Title: "B", Section: "blog", Date: time.Date(2023, 2, 1, 0, 0, 0, 0, time.UTC), Params: map[string]interface{}{"weight": 2}},
+		{Title: "A", Section: "docs", Date: time.Date(2023, 3, 1, 0, 0, 0, 0, time.UTC), Params: map[string]interface{}{"weight": 3}},
+		{Title: "C", Section: "blog", Date: time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC), Params: map[string]interface{}{"weight": 10}},
+	}
+	titles := func(pages []Page) []string {
+		names := []string{}
+		for _, page := range pages {
+			names = append(names, page.Title)
+		}
+		return names
+	}
+	tests := []struct {
+		name     string
+		pages    []Page
+		expected []string
+	}{
+		{"where field", where(pages, "Section", "blog"), []string{"B", "C"}},
+		{"where param", where(pages, "Params.weight", 3), []string{"A"}},
+		{"where unknown field", where(pages, "Nope", "blog"), []string{}},
+		{"sort by title", sortBy(pages, "Title"), []string{"A", "B", "C"}},
+		{"sort by date desc", sortBy(pages, "Date", "desc"), []string{"A", "B", "C"}},
+		{"sort by date", sortBy(pages, "Date"), []string{"C", "B", "A"}},
+		{"sort by number param", sortBy(pages, "Params.weight"), []string{"B", "A", "C"}},
+	}
+	for _, tt := range tests {
+		if got := titles(tt.pages); !reflect.DeepEqual(got, tt.expected) {
+			t.Errorf("%v: expected %v, got %v", tt.name, tt.expected, got)
+		}
+	}
+	if pages[0].Title != "B" {
+		t.Errorf("expected sortBy to leave the pages in place")
+	}
+}
+
+func TestReadFile(t *testing.T) {
+	app := App{SrcDir: "src_test"}
+	data, err := app.readFile("static/main.css")
+	if err != nil {
+		t.Fatalf("expected readFile to return no error, got %v", err)
+	}
+	expected, _ := os.ReadFile(filepath.Join("src_test", "static", "main.css"))
+	if data != string(expected) {
+		t.Errorf("expected the file contents, got %q", data)
+	}
+	if _, err := app.readFile("../go.mod"); err == nil {
+		t.Errorf("expected files outside the source directory to be refused")
+	}
+}
+
+func TestJsonify(t *testing.T) {
+	got, err := jsonify(map[string]interface{}{"title": "A & B"})
+	if err != nil || string(got) != `{"title":"A \u0026 B"}` {
+		t.Errorf("unexpected json %q, %v", got, err)
+	}
+}
+
+func TestTemplateModes(t *testing.T) {
+	page := Page{
+		Title:    `<script>alert("hi")</script>`,
+		Body:     "<p>body</p>",
+		Layout:   "page",
+		Filepath: filepath.Join("src", "page.md"),
+		Params:   map[string]interface{}{"tags": []interface{}{"Go"}},
+	}
+	tests := []struct {
+		mode     string
+		expected string
+	}{
+		{textTemplates, `<h1><script>alert("hi")</script></h1><p>body</p><a href="/tags/go/">go</a>`},
+		{htmlTemplates, `<h1>&lt;script&gt;alert(&#34;hi&#34;)&lt;/script&gt;</h1><p>body</p><a href="/tags/go/">go</a>`},
+		{"", `<h1>&lt;script&gt;alert(&#34;hi&#34;)&lt;/script&gt;</h1><p>body</p><a href="/tags/go/">go</a>`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.mode, func(t *testing.T) {
+			app := App{
+				SrcDir:       "src",
+				Config:       SquatchConfig{Templates: tt.mode},
+				SiteTemplate: `{{.Body}}`,
+				Layouts: map[string]string{
+					"page": `<h1>{{.Title}}</h1>{{.Body}}{{range .Params.tags}}<a href="{{relURL (printf "/tags/%s/" (slugify .))}}">{{slugify .}}</a>{{end}}`,
+				},
+			}
+			tmpl, root, err := app.layoutTemplates(page)
+			if err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+			var out bytes.Buffer
+			if err := tmpl.ExecuteTemplate(&out, root, page); err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+			if out.String() != tt.expected {
+				t.Errorf("Expected: %s, got: %s", tt.expected, out.String())
+			}
+		})
+	}
+}
+
+func TestTemplateModeConfig(t *testing.T) {
+	fp := filepath.Join(t.TempDir(), ".squatch")
+	os.WriteFile(fp, []byte(`{"templates": "jinja"}`), 0644)
+	if _, err := getSquatchConfig(fp); err == nil {
+		t.Errorf("expected getSquatchConfig to reject unknown template modes")
+	}
+}
+
+func TestMarkdownify(t *testing.T) {
+	app := App{}
+	if got := app.markdownify("**bold**"); !strings.Contains(string(got), "<strong>bold</strong>") {
+		t.Errorf("expected markdown to be rendered, got %v", got)
+	}
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"html"
+	"html/template"
+	"strings"
+
+	"github.com/gomarkdown/markdown/ast"
+)
+
+const (
+	defaultTOCMinLevel = 2
+	defaultTOCMaxLevel = 3
+)
+
+// TOC is the table of contents of a page
+type TOC struct {
+	// Entries are the top level headings with the headings below them nested
+	Entries []*TOCEntry
+	// HTML is the table of contents rendered as nested lists
+	HTML template.HTML
+}
+
+// TOCEntry is a heading in the table of contents
+type TOCEntry struct {
+	Title    string
+	ID       string
+	Level    int
+	Children []*TOCEntry
+}
+
+// headingText returns the plain text of a heading
+func headingText(node ast.Node) string {
+	var text strings.Builder
+	ast.WalkFunc(node, func(node ast.Node, entering bool) ast.WalkStatus {
+		if !entering {
+			return ast.GoToNext
+		}
+		switch node := node.(type) {
+		case *ast.Text:
+			text.Write(node.Literal)
+		case *ast.Code:
+			text.Write(node.Literal)
+		}
+		return ast.GoToNext
+	})
+	return text.String()
+}
+
+// tocLevels returns the configured range of heading levels in the table of
+// contents
+func (app App) tocLevels() (int, int) {
+	min, max := app.Config.TOC.MinLevel, app.Config.TOC.MaxLevel
+	if min <= 0 {
+		min = defaultTOCMinLevel
+	}
+	if max <= 0 {
+		max = defaultTOCMaxLevel
+	}
+	return min, max
+}
+
+// buildTOC nests the headings within the configured levels under the closest
+// heading above them
+func (app App) buildTOC(headings []*ast.Heading) TOC {
+	min, max := app.tocLevels()
+	toc := TOC{Entries: []*TOCEntry{}}
+	stack := []*TOCEntry{}
+	for _, heading := range headings {
+		if heading.Level < min || heading.Level > max {
+			continue
+		}
+		entry := &TOCEntry{
+			Title:    headingText(heading),
+			ID:       heading.HeadingID,
+			Level:    heading.Level,
+			Children: []*TOCEntry{},
+		}
+		for len(stack) > 0 && stack[len(stack)-1].Level >= entry.Level {
+			stack = stack[:len(stack)-1]
+		}
+		if len(stack) == 0 {
+			toc.Entries = append(toc.Entries, entry)
+		} else {
+			parent := stack[len(stack)-1]
+			parent.Children = append(parent.Children, entry)
+		}
+		stack = append(stack, entry)
+	}
+	if len(toc.Entries) > 0 {
+		var b strings.Builder
+		b.WriteString(`<nav class="toc">`)
+		writeTOCList(&b, toc.Entries)
+		b.WriteString("</nav>")
+		toc.HTML = template.HTML(b.String())
+	}
+	return toc
+}
+
+func writeTOCList(b *strings.Builder, entries []*TOCEntry) {
+	b.WriteString("<ul>")
+	for _, entry := range entries {
+		b.WriteString(`<li><a href="#` + entry.ID + `">` + html.EscapeString(entry.Title) + "</a>")
+		if len(entry.Children) > 0 {
+			writeTOCList(b, entry.Children)
+		}
+		b.WriteString("</li>")
+	}
+	b.WriteString("</ul>")
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestBuildTOC(t *testing.T) {
+	md := "# Title\n\n## One\n\n### One A\n\n#### Too deep\n\n### One B\n\n## Two & More\n"
+	tests := []struct {
+		name     string
+		config   TOCConfig
+		expected string
+	}{
+		{"default levels", TOCConfig{},
+			`<nav class="toc"><ul><li><a href="#one">One</a><ul><li><a href="#one-a">One A</a></li><li><a href="#one-b">One B</a></li></ul></li><li><a href="#two--more">Two &amp; More</a></li></ul></nav>`},
+		{"top level only", TOCConfig{MinLevel: 1, MaxLevel: 1},
+			`<nav class="toc"><ul><li><a href="#title">Title</a></li></ul></nav>`},
+		{"no headings in range", TOCConfig{MinLevel: 5, MaxLevel: 6}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := App{Config: SquatchConfig{TOC: tt.config}}
+			_, toc := app.renderMarkdown([]byte(md))
+			if string(toc.HTML) != tt.expected {
+				t.Errorf("Expected: %s, got: %s", tt.expected, toc.HTML)
+			}
+		})
+	}
+}
+
+func TestGetPageTOC(t *testing.T) {
+	app := App{}
+	page, err := app.getPage("src_test/pages/toc.md")
+	if err != nil {
+		t.Fatalf("expected getPage to return no error, got %v", err)
+	}
+	if len(page.TOC.Entries) != 2 {
+		t.Fatalf("expected 2 top level entries, got %v", len(page.TOC.Entries))
+	}
+	first := page.TOC.Entries[0]
+	if first.Title != "Install" || first.ID != "install" || first.Level != 2 {
+		t.Errorf("unexpected first entry %+v", first)
+	}
+	if len(first.Children) != 1 || first.Children[0].ID != "from-source" {
+		t.Errorf("expected a nested entry for From source, got %+v", first.Children)
+	}
+	if !strings.Contains(string(page.Body), `<h3 id="from-source">`) {
+		t.Errorf("expected the heading ids in the body, got %v", page.Body)
+	}
+}
+
+func TestRenderTOC(t *testing.T) {
+	srcTest := "src_test"
+	defer cleanup("dist")
+	Build(srcTest)
+	data, err := os.ReadFile(filepath.Join("dist", "pages", "toc.html"))
+	if err != nil {
+		t.Fatalf("expected toc.html to exist, got %v", err)
+	}
+	if !strings.Contains(string(data), `<nav class="toc"><ul><li><a href="#install">Install</a>`) {
+		t.Errorf("expected the table of contents in the page, got %v", string(data))
+	}
+}